@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// vars
+var (
+	ErrBindingMismatch = errors.New("token is bound to another client")
+)
+
+// Binding client attributes a session is bound to, combine with |
+type Binding int
+
+// bindings
+const (
+	BindUserAgent Binding = 1 << iota // User-Agent family, versions ignored
+	BindIPPrefix                      // client IP /24 for IPv4, /64 for IPv6, see ClientIP
+	BindCert                          // thumbprint of TLS client certificate
+)
+
+// BindMode what to do with a mismatched client
+type BindMode int
+
+// bind modes
+const (
+	BindReject BindMode = iota // reject the token, default
+	BindFlag                   // accept the token, call the hook only
+)
+
+// BindingHook called when a token is presented from a mismatched client
+type BindingHook func(r *http.Request, user *User)
+
+// WithBinding The option bind sessions to client attributes, call Bind before Signin,
+// tokens without a fingerprint are mismatched too. It requires WithSecret, the
+// fingerprint is keyed and all tokens are rejected without
+func WithBinding(b Binding, mode BindMode) OptFunc {
+	return func(opt *option) {
+		opt.Binding = b
+		opt.BindMode = mode
+	}
+}
+
+// WithBindingHook set the hook of mismatched clients
+func WithBindingHook(fn BindingHook) OptFunc {
+	return func(opt *option) {
+		opt.OnMismatch = fn
+	}
+}
+
+// binder bind users to clients, implemented by authorizers of this package
+type binder interface {
+	bind(user *User, r *http.Request)
+}
+
+// Bind embed the fingerprint of client into user before Signin, do nothing without
+// WithBinding (or WithSecret) of a
+func Bind(a Authorizer, user *User, r *http.Request) {
+	if b, ok := a.(binder); ok {
+		b.bind(user, r)
+	}
+}
+
+func (opt *option) bind(user *User, r *http.Request) {
+	if opt.Binding != 0 && opt.signed() {
+		user.Bound = opt.fingerprint(r, opt.Secrets[0])
+	}
+}
+
+func (opt *option) checkBinding(r *http.Request, user *User) error {
+	for _, key := range opt.Secrets {
+		if hmac.Equal([]byte(user.Bound), []byte(opt.fingerprint(r, key))) {
+			return nil
+		}
+	}
+	slog.Info("binding mismatch", "uid", user.UID, "ip", opt.clientIP(r), "ua", r.UserAgent())
+	if opt.OnMismatch != nil {
+		opt.OnMismatch(r, user)
+	}
+	if opt.BindMode == BindFlag {
+		return nil
+	}
+	return ErrBindingMismatch
+}
+
+// fingerprint return a keyed hash of selected client attributes
+func (opt *option) fingerprint(r *http.Request, key []byte) string {
+	b := opt.Binding
+	h := hmac.New(sha256.New, key)
+	if b&BindUserAgent != 0 {
+		h.Write([]byte(uaFamily(r.UserAgent())))
+	}
+	h.Write([]byte{0})
+	if b&BindIPPrefix != 0 {
+		h.Write([]byte(ipPrefix(opt.clientIP(r))))
+	}
+	h.Write([]byte{0})
+	if b&BindCert != 0 && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		sum := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+		h.Write(sum[:])
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
+
+// uaFamily strip version numbers of User-Agent, keep it stable across upgrades
+func uaFamily(ua string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || r == '.' || r == '_' {
+			return -1
+		}
+		return r
+	}, ua)
+}
+
+// ipPrefix return the /24 network of IPv4 or /64 of IPv6
+func ipPrefix(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String()
+}
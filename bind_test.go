@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, "10.1.2.0", ipPrefix("10.1.2.3"))
+	assert.Equal(t, "2001:db8:1:2::", ipPrefix("2001:db8:1:2:3:4:5:6"))
+	assert.Equal(t, uaFamily("Mozilla/5.0 Firefox/128.0"), uaFamily("Mozilla/5.0 Firefox/131.0.1"))
+	assert.NotEqual(t, uaFamily("Mozilla/5.0 Firefox/128.0"), uaFamily("curl/8.4.0"))
+}
+
+func TestBinding(t *testing.T) {
+	var flagged []string
+	hook := WithBindingHook(func(r *http.Request, u *User) { flagged = append(flagged, u.UID) })
+	a := New(WithBinding(BindUserAgent|BindIPPrefix, BindReject), hook, WithSecret(testSecret))
+
+	newReq := func(ip, ua string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":4321"
+		req.Header.Set("User-Agent", ua)
+		return req
+	}
+	login := newReq("192.0.2.10", "Mozilla/5.0 Firefox/128.0")
+	user := &User{UID: "alice"}
+	user.Refresh()
+	Bind(a, user, login)
+	assert.NotEmpty(t, user.Bound)
+	assert.NotEqual(t, user.Bound, a.(*option).fingerprint(login, []byte("other")))
+
+	w := httptest.NewRecorder()
+	assert.Nil(t, a.Signin(user, w))
+	ck := w.Result().Cookies()[0]
+
+	try := func(a Authorizer, req *http.Request) error {
+		req.AddCookie(ck)
+		_, err := a.UserFromRequest(req)
+		return err
+	}
+	assert.Nil(t, try(a, newReq("192.0.2.99", "Mozilla/5.0 Firefox/131.0")))
+	assert.Equal(t, ErrBindingMismatch, try(a, newReq("198.51.100.1", "Mozilla/5.0 Firefox/128.0")))
+	assert.Equal(t, ErrBindingMismatch, try(a, newReq("192.0.2.10", "curl/8.4.0")))
+	assert.Equal(t, []string{"alice", "alice"}, flagged)
+
+	// flag mode accepts with the hook called
+	flag := New(WithBinding(BindUserAgent|BindIPPrefix, BindFlag), hook, WithSecret(testSecret))
+	assert.Nil(t, try(flag, newReq("198.51.100.1", "curl/8.4.0")))
+	assert.Len(t, flagged, 3)
+
+	// rotated keys
+	rotated := New(WithBinding(BindUserAgent|BindIPPrefix, BindReject), WithSecret([]byte("new key"), testSecret))
+	assert.Nil(t, try(rotated, newReq("192.0.2.10", "Mozilla/5.0 Firefox/128.0")))
+
+	// no secret
+	nokey := New(WithBinding(BindUserAgent|BindIPPrefix, BindReject))
+	assert.Equal(t, ErrNoSecret, try(nokey, newReq("192.0.2.10", "Mozilla/5.0 Firefox/128.0")))
+
+	// unbound tokens are mismatched
+	unbound := &User{UID: "bob"}
+	unbound.Refresh()
+	w = httptest.NewRecorder()
+	assert.Nil(t, a.Signin(unbound, w))
+	ck = w.Result().Cookies()[0]
+	assert.Equal(t, ErrBindingMismatch, try(a, newReq("192.0.2.10", "Mozilla/5.0 Firefox/128.0")))
+
+	// binding disabled
+	plain := New()
+	Bind(plain, unbound, login)
+	assert.Empty(t, unbound.Bound)
+	w = httptest.NewRecorder()
+	assert.Nil(t, plain.Signin(unbound, w))
+	ck = w.Result().Cookies()[0]
+	assert.Nil(t, try(plain, newReq("198.51.100.1", "curl/8.4.0")))
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// chain try authorizers in order, the first is the primary
+type chain struct {
+	list []Authorizer
+}
+
+var (
+	_ Authorizer = (*chain)(nil)
+	_ tokenCodec = (*chain)(nil)
+	_ binder     = (*chain)(nil)
+	_ ipResolver = (*chain)(nil)
+	_ stepper    = (*chain)(nil)
+)
+
+// Chain return an Authorizer which tries authorizers in order and stops at the
+// first success, the first one is the primary for Signin, Signout and others
+// which issue tokens, e.g. cookie sessions, then Bearer tokens, then API keys
+func Chain(authorizers ...Authorizer) Authorizer {
+	if len(authorizers) == 0 {
+		authorizers = []Authorizer{Default()}
+	}
+	return &chain{list: authorizers}
+}
+
+func (c *chain) primary() Authorizer {
+	return c.list[0]
+}
+
+// userFrom return user and the authorizer accepted it, or the combined errors
+func (c *chain) userFrom(r *http.Request) (*User, Authorizer, error) {
+	var errs []error
+	for _, a := range c.list {
+		user, err := a.UserFromRequest(r)
+		if err == nil {
+			return user, a, nil
+		}
+		if err != ErrNoTokenInRequest {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, nil, ErrNoTokenInRequest
+	}
+	return nil, nil, errors.Join(errs...)
+}
+
+func (c *chain) UserFromRequest(r *http.Request) (*User, error) {
+	user, _, err := c.userFrom(r)
+	return user, err
+}
+
+func (c *chain) Middleware() func(next http.Handler) http.Handler {
+	return c.MiddlewareWordy(false)
+}
+
+func (c *chain) MiddlewareWordy(redir bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			user, a, err := c.userFrom(req)
+			if err != nil {
+				slog.Info("chain auth fail", "err", err)
+				if opt, ok := c.primary().(*option); ok {
+					opt.deny(rw, req, err, redir)
+				} else {
+					http.Error(rw, err.Error(), http.StatusUnauthorized)
+				}
+				return
+			}
+			if opt, ok := a.(*option); ok {
+				opt.serveUser(rw, req, user, next)
+				return
+			}
+			next.ServeHTTP(rw, req.WithContext(ContextWithUser(req.Context(), user)))
+		})
+	}
+}
+
+func (c *chain) stepURI() string {
+	if st, ok := c.primary().(stepper); ok {
+		return st.stepURI()
+	}
+	return ""
+}
+
+func (c *chain) TokenFromRequest(r *http.Request) (s string, err error) {
+	for _, a := range c.list {
+		if s, err = a.TokenFromRequest(r); err == nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *chain) TokenFrom(args ...any) string {
+	for _, a := range c.list {
+		if s := a.TokenFrom(args...); len(s) > 0 {
+			return s
+		}
+	}
+	return ""
+}
+
+func (c *chain) Cooking(value string) *http.Cookie {
+	return c.primary().Cooking(value)
+}
+
+func (c *chain) Signin(user Encoder, w http.ResponseWriter) error {
+	return c.primary().Signin(user, w)
+}
+
+func (c *chain) bind(user *User, r *http.Request) {
+	Bind(c.primary(), user, r)
+}
+
+func (c *chain) clientIP(r *http.Request) string {
+	return ClientIP(c.primary(), r)
+}
+
+func (c *chain) Signout(w http.ResponseWriter) {
+	c.primary().Signout(w)
+}
+
+// With apply options to the primary
+func (c *chain) With(opts ...OptFunc) {
+	c.primary().With(opts...)
+}
+
+func (c *chain) signed() bool {
+	tc, ok := c.primary().(tokenCodec)
+	return ok && tc.signed()
+}
+
+func (c *chain) encodeToken(user Encoder) (string, error) {
+	tc, err := codecOf(c.primary(), false)
+	if err != nil {
+		return "", err
+	}
+	return tc.encodeToken(user)
+}
+
+func (c *chain) deriveKeys(label string) [][]byte {
+	if tc, ok := c.primary().(tokenCodec); ok {
+		return tc.deriveKeys(label)
+	}
+	return nil
+}
+
+// parseToken try members of this package in order
+func (c *chain) parseToken(token string) (user *User, err error) {
+	err = ErrInvalidSignature
+	for _, a := range c.list {
+		if tc, ok := a.(tokenCodec); ok {
+			if user, err = tc.parseToken(token); err == nil {
+				return
+			}
+		}
+	}
+	return
+}
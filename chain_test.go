@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errBadKey = errors.New("bad api key")
+
+// apiKeys an Authorizer of bots by X-Api-Key
+type apiKeys struct {
+	Authorizer
+	keys map[string]string
+}
+
+func (k apiKeys) UserFromRequest(r *http.Request) (*User, error) {
+	key := r.Header.Get("X-Api-Key")
+	if len(key) == 0 {
+		return nil, ErrNoTokenInRequest
+	}
+	if uid, ok := k.keys[key]; ok {
+		return &User{UID: uid}, nil
+	}
+	return nil, errBadKey
+}
+
+func TestChain(t *testing.T) {
+	web := New(WithCookie("_web"), WithURI("/login"))
+	partner := New(WithCookie("_partner"))
+	bots := apiKeys{Authorizer: New(), keys: map[string]string{"k1": "bot"}}
+	c := Chain(web, partner, bots)
+
+	h := c.MiddlewareWordy(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := UserFromContext(r.Context())
+		_, _ = io.WriteString(w, user.UID)
+	}))
+	do := func(set func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		set(req)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// signin goes to the primary
+	w := httptest.NewRecorder()
+	alice := &User{UID: "alice"}
+	alice.Refresh()
+	assert.Nil(t, c.Signin(alice, w))
+	ck := w.Result().Cookies()[0]
+	assert.Equal(t, "_web", ck.Name)
+
+	assert.Equal(t, "alice", do(func(r *http.Request) { r.AddCookie(ck) }).Body.String())
+	assert.Equal(t, "bot", do(func(r *http.Request) { r.Header.Set("X-Api-Key", "k1") }).Body.String())
+
+	w = do(func(r *http.Request) {})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+
+	// combined failure reasons
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "_web", Value: "garbage"})
+	req.Header.Set("X-Api-Key", "nope")
+	_, err := c.UserFromRequest(req)
+	assert.ErrorIs(t, err, errBadKey)
+	assert.Contains(t, err.Error(), "bad api key")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err = c.UserFromRequest(req)
+	assert.Equal(t, ErrNoTokenInRequest, err)
+
+	w = httptest.NewRecorder()
+	c.Signout(w)
+	assert.Equal(t, "_web", w.Result().Cookies()[0].Name)
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// vars
+var (
+	ErrInvalidDPoP  = errors.New("invalid dpop proof")
+	ErrDPoPReplay   = errors.New("dpop proof is replayed")
+	ErrDPoPRequired = errors.New("dpop proof is required")
+)
+
+// Thumbprint return the JWK SHA-256 thumbprint of RFC 7638
+func (k *JWK) Thumbprint() string {
+	var b []byte
+	switch k.Kty {
+	case "EC":
+		b, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y})
+	case "RSA":
+		b, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N})
+	default:
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// DPoP verify proof-of-possession headers of RFC 9449
+type DPoP struct {
+	store OnceStore
+
+	BaseURL string        // scheme and host of htu, e.g. https://api.example.net, default from request
+	Skew    time.Duration // max age of iat, default 1 minute
+}
+
+// NewDPoP return a DPoP with a replay cache of jti, default: in-memory
+func NewDPoP(store OnceStore) *DPoP {
+	if store == nil {
+		store = NewOnceStore()
+	}
+	return &DPoP{store: store, Skew: time.Minute}
+}
+
+// WithDPoP The option verify DPoP proofs of bound tokens, bound tokens are rejected without it.
+// It requires WithSecret, otherwise the binding could be stripped from tokens
+func WithDPoP(d *DPoP) OptFunc {
+	return func(opt *option) {
+		opt.DPoP = d
+	}
+}
+
+// Bind verify the proof of a token request and bind user to its key
+func (d *DPoP) Bind(r *http.Request, user *User) error {
+	jkt, err := d.Verify(r, "")
+	if err != nil {
+		return err
+	}
+	user.JKT = jkt
+	return nil
+}
+
+// Verify check the DPoP header of request, with the access token if not empty,
+// return the key thumbprint
+func (d *DPoP) Verify(r *http.Request, token string) (string, error) {
+	s := r.Header.Get("DPoP")
+	if len(s) == 0 {
+		return "", ErrDPoPRequired
+	}
+	t, err := parseJWT(s)
+	if err != nil || t.Header.Typ != "dpop+jwt" || t.Header.JWK == nil {
+		return "", ErrInvalidDPoP
+	}
+	pk, err := t.Header.JWK.PublicKey()
+	if err != nil {
+		return "", ErrInvalidDPoP
+	}
+	if err = t.verify(pk); err != nil {
+		return "", err
+	}
+	if t.claimString("htm") != r.Method || t.claimString("htu") != d.targetURI(r) {
+		return "", ErrInvalidDPoP
+	}
+	iat := time.Unix(t.claimInt("iat"), 0)
+	if age := time.Since(iat); age > d.Skew || age < -d.Skew {
+		slog.Info("dpop proof is stale", "iat", iat)
+		return "", ErrInvalidDPoP
+	}
+	if len(token) > 0 {
+		sum := sha256.Sum256([]byte(token))
+		if t.claimString("ath") != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return "", ErrInvalidDPoP
+		}
+	}
+	jti := t.claimString("jti")
+	if len(jti) == 0 {
+		return "", ErrInvalidDPoP
+	}
+	ok, err := d.store.Consume(r.Context(), "dpop:"+jti, iat.Add(2*d.Skew))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrDPoPReplay
+	}
+	return t.Header.JWK.Thumbprint(), nil
+}
+
+// targetURI return the request URI without query and fragment
+func (d *DPoP) targetURI(r *http.Request) string {
+	if len(d.BaseURL) > 0 {
+		return d.BaseURL + r.URL.Path
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// checkDPoP verify the proof of a bound token
+func (opt *option) checkDPoP(r *http.Request, user *User, token string) error {
+	return checkDPoP(opt.DPoP, r, user, token)
+}
+
+// checkDPoP verify the proof of a bound token by d, fail if d is nil
+func checkDPoP(d *DPoP, r *http.Request, user *User, token string) error {
+	if d == nil {
+		slog.Info("dpop is not enabled", "uid", user.UID)
+		return ErrDPoPRequired
+	}
+	if len(user.JKT) == 0 {
+		slog.Info("dpop scheme with an unbound token", "uid", user.UID)
+		return ErrInvalidDPoP
+	}
+	jkt, err := d.Verify(r, token)
+	if err != nil {
+		slog.Info("dpop verify fail", "uid", user.UID, "err", err)
+		return err
+	}
+	if jkt != user.JKT {
+		slog.Info("dpop key mismatch", "uid", user.UID)
+		return ErrInvalidDPoP
+	}
+	return nil
+}
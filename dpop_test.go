@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type dpopKey struct {
+	pk  *ecdsa.PrivateKey
+	jwk *JWK
+}
+
+func newDPoPKey(t *testing.T) *dpopKey {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	b64 := base64.RawURLEncoding
+	return &dpopKey{pk: pk, jwk: &JWK{
+		Kty: "EC", Crv: "P-256",
+		X: b64.EncodeToString(pk.X.FillBytes(make([]byte, 32))),
+		Y: b64.EncodeToString(pk.Y.FillBytes(make([]byte, 32))),
+	}}
+}
+
+func (k *dpopKey) proof(t *testing.T, method, uri, token string, iat time.Time) string {
+	b64 := base64.RawURLEncoding
+	hb, _ := json.Marshal(jwtHeader{Alg: "ES256", Typ: "dpop+jwt", JWK: k.jwk})
+	claims := map[string]any{"htm": method, "htu": uri, "iat": iat.Unix(), "jti": randString(12)}
+	if token != "" {
+		sum := sha256.Sum256([]byte(token))
+		claims["ath"] = b64.EncodeToString(sum[:])
+	}
+	pb, _ := json.Marshal(claims)
+	input := b64.EncodeToString(hb) + "." + b64.EncodeToString(pb)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, k.pk, digest[:])
+	assert.Nil(t, err)
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func TestDPoP(t *testing.T) {
+	key := newDPoPKey(t)
+	d := NewDPoP(nil)
+	a := New(WithDPoP(d), WithSecret(testSecret))
+
+	// token request
+	req := httptest.NewRequest(http.MethodPost, "http://api.example.net/token", nil)
+	req.Header.Set("DPoP", key.proof(t, http.MethodPost, "http://api.example.net/token", "", time.Now()))
+	user := &User{UID: "mobile"}
+	user.Refresh()
+	assert.Nil(t, d.Bind(req, user))
+	assert.Equal(t, key.jwk.Thumbprint(), user.JKT)
+	token := signToken(t, a, user)
+
+	call := func(a Authorizer, proof string) error {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.net/items?page=2", nil)
+		req.Header.Set("Authorization", "DPoP "+token)
+		if proof != "" {
+			req.Header.Set("DPoP", proof)
+		}
+		_, err := a.UserFromRequest(req)
+		return err
+	}
+	uri := "http://api.example.net/items"
+	proof := key.proof(t, http.MethodGet, uri, token, time.Now())
+	assert.Nil(t, call(a, proof))
+	assert.Equal(t, ErrDPoPReplay, call(a, proof))
+	assert.Equal(t, ErrDPoPRequired, call(a, ""))
+	assert.Equal(t, ErrDPoPRequired, call(New(WithSecret(testSecret)), key.proof(t, http.MethodGet, uri, token, time.Now())))
+	assert.Equal(t, ErrNoSecret, call(New(WithDPoP(d)), key.proof(t, http.MethodGet, uri, token, time.Now())))
+
+	assert.Equal(t, ErrInvalidDPoP, call(a, key.proof(t, http.MethodPost, uri, token, time.Now())))
+	assert.Equal(t, ErrInvalidDPoP, call(a, key.proof(t, http.MethodGet, "http://api.example.net/other", token, time.Now())))
+	assert.Equal(t, ErrInvalidDPoP, call(a, key.proof(t, http.MethodGet, uri, token, time.Now().Add(-time.Hour))))
+	assert.Equal(t, ErrInvalidDPoP, call(a, key.proof(t, http.MethodGet, uri, "other", time.Now())))
+
+	// a stolen token with another key
+	thief := newDPoPKey(t)
+	assert.Equal(t, ErrInvalidDPoP, call(a, thief.proof(t, http.MethodGet, uri, token, time.Now())))
+
+	// the binding can't be stripped
+	stripped := *user
+	stripped.JKT = ""
+	s, _ := stripped.Encode()
+	token = s + token[strings.LastIndexByte(token, '.'):]
+	assert.Equal(t, ErrInvalidSignature, call(a, thief.proof(t, http.MethodGet, uri, token, time.Now())))
+
+	// unbound tokens need no proof with Bearer, but are rejected with DPoP
+	plain := &User{UID: "web"}
+	plain.Refresh()
+	token = signToken(t, a, plain)
+	assert.Equal(t, ErrInvalidDPoP, call(a, thief.proof(t, http.MethodGet, uri, token, time.Now())))
+	req = httptest.NewRequest(http.MethodGet, uri, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	_, err := a.UserFromRequest(req)
+	assert.Nil(t, err)
+}
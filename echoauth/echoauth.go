@@ -0,0 +1,96 @@
+// Package echoauth adapt simpauth to Echo
+package echoauth
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	auth "github.com/liut/simpauth"
+)
+
+// UserKey the key of user in echo.Context
+const UserKey = "simpauth.user"
+
+// Auth wrap an Authorizer for echo.Context
+type Auth struct {
+	a auth.Authorizer
+}
+
+// New return an Auth of a, default: auth.Default()
+func New(a auth.Authorizer) *Auth {
+	if a == nil {
+		a = auth.Default()
+	}
+	return &Auth{a: a}
+}
+
+// Middleware ...
+func (ea *Auth) Middleware() echo.MiddlewareFunc {
+	return ea.MiddlewareWordy(false)
+}
+
+// MiddlewareWordy run the Middleware of Authorizer, so that redirect, refresh and
+// all other options work the same as net/http, then put the user into echo.Context
+// and the request context, failures return an *echo.HTTPError
+func (ea *Auth) MiddlewareWordy(redir bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			w, req := auth.RecordMiddleware(ea.a.MiddlewareWordy(redir), c.Request())
+			if req == nil { // rendered by echo
+				w.Header().Del("Content-Type")
+				w.Header().Del("X-Content-Type-Options")
+			}
+			for k, vs := range w.Header() {
+				for _, v := range vs {
+					c.Response().Header().Add(k, v)
+				}
+			}
+			if req == nil {
+				if len(w.Header().Get("Location")) > 0 {
+					return c.NoContent(w.Code)
+				}
+				return echo.NewHTTPError(w.Code, strings.TrimSpace(string(w.Body)))
+			}
+			user, _ := auth.UserFromContext(req.Context())
+			c.SetRequest(req)
+			c.Set(UserKey, user)
+			return next(c)
+		}
+	}
+}
+
+// UserFrom return the user put by Middleware
+func UserFrom(c echo.Context) (*auth.User, bool) {
+	user, ok := c.Get(UserKey).(*auth.User)
+	return user, ok
+}
+
+// RequireRoles require the user has one of roles, see auth.RolesInContext, use after Middleware
+func RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, ok := UserFrom(c)
+			if !ok {
+				return echo.ErrUnauthorized
+			}
+			have := auth.RolesInContext(c.Request().Context(), user)
+			for _, r := range roles {
+				if have.Has(r) {
+					return next(c)
+				}
+			}
+			return echo.ErrForbidden
+		}
+	}
+}
+
+// Signin write user encoded string into cookie
+func (ea *Auth) Signin(c echo.Context, user auth.Encoder) error {
+	return ea.a.Signin(user, c.Response())
+}
+
+// Signout setcookie with empty
+func (ea *Auth) Signout(c echo.Context) {
+	ea.a.Signout(c.Response())
+}
@@ -0,0 +1,101 @@
+package echoauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	auth "github.com/liut/simpauth"
+)
+
+func newEcho(t *testing.T, ea *Auth, redir bool) *echo.Echo {
+	e := echo.New()
+	e.POST("/login", func(c echo.Context) error {
+		user := &auth.User{UID: c.FormValue("uid")}
+		user.Refresh()
+		return ea.Signin(c, user)
+	})
+	e.POST("/logout", func(c echo.Context) error {
+		ea.Signout(c)
+		return nil
+	})
+	me := func(c echo.Context) error {
+		user, ok := UserFrom(c)
+		assert.True(t, ok)
+		fromCtx, ok := auth.UserFromContext(c.Request().Context())
+		assert.True(t, ok)
+		assert.Equal(t, user, fromCtx)
+		return c.String(http.StatusOK, user.UID)
+	}
+	e.GET("/me", me, ea.MiddlewareWordy(redir))
+	e.GET("/admin", me, ea.Middleware(), RequireRoles("admin"))
+	return e
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestEchoAuth(t *testing.T) {
+	ea := New(auth.New(auth.WithCookie("_sess", "/", "example.net")))
+	e := newEcho(t, ea, false)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.net/login", strings.NewReader("uid=alice"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ck := serve(e, req).Result().Cookies()[0]
+	assert.Equal(t, "_sess", ck.Name)
+	assert.Equal(t, "example.net", ck.Domain)
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.net/me", nil)
+	req.AddCookie(ck)
+	w := serve(e, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+
+	w = serve(e, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	var body map[string]string
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, auth.ErrNoTokenInRequest.Error(), body["message"])
+
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.AddCookie(ck)
+	assert.Equal(t, http.StatusForbidden, serve(e, req).Code)
+
+	root := &auth.User{UID: "root", Roles: auth.Names{"admin"}}
+	root.Refresh()
+	token, _ := root.Encode()
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, "root", serve(e, req).Body.String())
+
+	out := serve(e, httptest.NewRequest(http.MethodPost, "/logout", nil)).Result().Cookies()[0]
+	assert.Equal(t, "_sess", out.Name)
+	assert.Equal(t, -1, out.MaxAge)
+}
+
+func TestEchoRedirectRefresh(t *testing.T) {
+	ea := New(auth.New(auth.WithURI("/login"), auth.WithRefreshHeader("", auth.RefreshHeader)))
+	e := newEcho(t, ea, true)
+
+	w := serve(e, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+
+	old := &auth.User{UID: "bob", LastHit: time.Now().Add(-50 * time.Minute).Unix()}
+	token, _ := old.Encode()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = serve(e, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("X-Refreshed-Token"))
+}
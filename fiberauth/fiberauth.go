@@ -0,0 +1,86 @@
+// Package fiberauth adapt simpauth to Fiber
+package fiberauth
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+
+	auth "github.com/liut/simpauth"
+)
+
+type ctxKey struct{}
+
+// Auth wrap an Authorizer for fiber.Ctx
+type Auth struct {
+	a auth.Authorizer
+}
+
+// New return an Auth of a, default: auth.Default()
+func New(a auth.Authorizer) *Auth {
+	if a == nil {
+		a = auth.Default()
+	}
+	return &Auth{a: a}
+}
+
+// Middleware ...
+func (fa *Auth) Middleware() fiber.Handler {
+	return fa.MiddlewareWordy(false)
+}
+
+// MiddlewareWordy run the Middleware of Authorizer, so that redirect, refresh and
+// all other options work the same as net/http, then put the user into Locals
+// and UserContext. The converted request keeps the TLS state of the connection,
+// so that client certificates (BindCert, WithCertMapper) and the https scheme of
+// DPoP htu work if Fiber terminates TLS
+func (fa *Auth) MiddlewareWordy(redir bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req, err := adaptor.ConvertRequest(c, true)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		w, next := auth.RecordMiddleware(fa.a.MiddlewareWordy(redir), req)
+		copyHeader(c, w.Header())
+		if next == nil {
+			return c.Status(w.Code).Send(w.Body)
+		}
+		user, _ := auth.UserFromContext(next.Context())
+		c.Locals(ctxKey{}, user)
+		c.SetUserContext(auth.ContextWithUser(c.UserContext(), user))
+		return c.Next()
+	}
+}
+
+// UserFrom return the user put by Middleware
+func UserFrom(c *fiber.Ctx) (*auth.User, bool) {
+	user, ok := c.Locals(ctxKey{}).(*auth.User)
+	return user, ok
+}
+
+// Signin write user encoded string into cookie, signed with the options of Authorizer
+func (fa *Auth) Signin(c *fiber.Ctx, user auth.Encoder) error {
+	w := auth.NewRecorder()
+	if err := fa.a.Signin(user, w); err != nil {
+		return err
+	}
+	copyHeader(c, w.Header())
+	return nil
+}
+
+// Signout setcookie with empty
+func (fa *Auth) Signout(c *fiber.Ctx) {
+	w := auth.NewRecorder()
+	fa.a.Signout(w)
+	copyHeader(c, w.Header())
+}
+
+// copyHeader add the recorded header into the response of c
+func copyHeader(c *fiber.Ctx, header http.Header) {
+	for k, vs := range header {
+		for _, v := range vs {
+			c.Response().Header.Add(k, v)
+		}
+	}
+}
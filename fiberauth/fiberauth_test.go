@@ -0,0 +1,142 @@
+package fiberauth
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	auth "github.com/liut/simpauth"
+)
+
+func newApp(t *testing.T, fa *Auth, redir bool) *fiber.App {
+	app := fiber.New()
+	app.Post("/login", func(c *fiber.Ctx) error {
+		user := &auth.User{UID: c.FormValue("uid")}
+		user.Refresh()
+		return fa.Signin(c, user)
+	})
+	app.Post("/logout", func(c *fiber.Ctx) error {
+		fa.Signout(c)
+		return nil
+	})
+	app.Get("/me", fa.MiddlewareWordy(redir), func(c *fiber.Ctx) error {
+		user, ok := UserFrom(c)
+		assert.True(t, ok)
+		fromCtx, ok := auth.UserFromContext(c.UserContext())
+		assert.True(t, ok)
+		assert.Equal(t, user, fromCtx)
+		return c.SendString(user.UID)
+	})
+	return app
+}
+
+func TestFiberAuth(t *testing.T) {
+	fa := New(auth.New(auth.WithCookie("_sess", "/", "example.net"), auth.WithMaxAge(600),
+		auth.WithSecret([]byte("0123456789abcdef0123456789abcdef"))))
+	app := newApp(t, fa, false)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.net/login", strings.NewReader("uid=alice"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	cks := res.Cookies()
+	assert.Len(t, cks, 1)
+	ck := cks[0]
+	assert.Equal(t, "_sess", ck.Name)
+	assert.Equal(t, "example.net", ck.Domain)
+	assert.Equal(t, 600, ck.MaxAge)
+	assert.True(t, ck.HttpOnly)
+	assert.Contains(t, ck.Value, ".", "signed with the secret")
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.net/me", nil)
+	req.AddCookie(&http.Cookie{Name: ck.Name, Value: ck.Value})
+	res, err = app.Test(req)
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(res.Body)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "alice", string(body))
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.net/me", nil)
+	res, err = app.Test(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	req = httptest.NewRequest(http.MethodPost, "http://example.net/logout", nil)
+	res, err = app.Test(req)
+	assert.Nil(t, err)
+	cks = res.Cookies()
+	// the host-only cookie is expired too
+	assert.Len(t, cks, 2)
+	for _, ck := range cks {
+		assert.Equal(t, "_sess", ck.Name)
+		assert.Empty(t, ck.Value)
+		assert.True(t, ck.MaxAge < 0 || ck.Expires.Before(time.Now()))
+	}
+}
+
+func TestFiberRedirectRefresh(t *testing.T) {
+	fa := New(auth.New(auth.WithURI("/login"), auth.WithRefreshHeader("", auth.RefreshHeader)))
+	app := newApp(t, fa, true)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, "/login", res.Header.Get("Location"))
+
+	old := &auth.User{UID: "bob", LastHit: time.Now().Add(-50 * time.Minute).Unix()}
+	token, _ := old.Encode()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err = app.Test(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEmpty(t, res.Header.Get("X-Refreshed-Token"))
+}
+
+// tlsSpy record the TLS state of requests seen by the Authorizer
+type tlsSpy struct {
+	auth.Authorizer
+	state *tls.ConnectionState
+}
+
+func (s *tlsSpy) MiddlewareWordy(redir bool) func(next http.Handler) http.Handler {
+	mw := s.Authorizer.MiddlewareWordy(redir)
+	return func(next http.Handler) http.Handler {
+		h := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.state = r.TLS
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestFiberTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	defer srv.Close()
+	cfg := srv.TLS.Clone()
+	cfg.NextProtos = nil
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	spy := &tlsSpy{Authorizer: auth.New()}
+	app := newApp(t, New(spy), false)
+	go func() { _ = app.Listener(tls.NewListener(ln, cfg)) }()
+	defer func() { _ = app.Shutdown() }()
+
+	res, err := srv.Client().Get("https://" + ln.Addr().String() + "/me")
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	if assert.NotNil(t, spy.state) {
+		assert.True(t, spy.state.HandshakeComplete)
+	}
+}
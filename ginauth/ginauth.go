@@ -0,0 +1,101 @@
+// Package ginauth adapt simpauth to Gin
+package ginauth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	auth "github.com/liut/simpauth"
+)
+
+// UserKey the key of user in gin.Context
+const UserKey = "simpauth.user"
+
+// Auth wrap an Authorizer for gin.Context
+type Auth struct {
+	a auth.Authorizer
+}
+
+// New return an Auth of a, default: auth.Default()
+func New(a auth.Authorizer) *Auth {
+	if a == nil {
+		a = auth.Default()
+	}
+	return &Auth{a: a}
+}
+
+// Middleware ...
+func (ga *Auth) Middleware() gin.HandlerFunc {
+	return ga.MiddlewareWordy(false)
+}
+
+// MiddlewareWordy run the Middleware of Authorizer, so that redirect, refresh and
+// all other options work the same as net/http, then put the user into gin.Context
+// and the request context, failures abort with a JSON error
+func (ga *Auth) MiddlewareWordy(redir bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, req := auth.RecordMiddleware(ga.a.MiddlewareWordy(redir), c.Request)
+		if req == nil { // rendered by gin
+			w.Header().Del("Content-Type")
+			w.Header().Del("X-Content-Type-Options")
+		}
+		for k, vs := range w.Header() {
+			for _, v := range vs {
+				c.Writer.Header().Add(k, v)
+			}
+		}
+		if req == nil {
+			if len(w.Header().Get("Location")) > 0 {
+				c.AbortWithStatus(w.Code)
+				return
+			}
+			c.AbortWithStatusJSON(w.Code, gin.H{"error": strings.TrimSpace(string(w.Body))})
+			return
+		}
+		user, _ := auth.UserFromContext(req.Context())
+		c.Request = req
+		c.Set(UserKey, user)
+		c.Next()
+	}
+}
+
+// UserFrom return the user put by Middleware
+func UserFrom(c *gin.Context) (*auth.User, bool) {
+	user, ok := c.Get(UserKey)
+	if !ok {
+		return nil, false
+	}
+	u, ok := user.(*auth.User)
+	return u, ok
+}
+
+// RequireRoles require the user has one of roles, see auth.RolesInContext, use after Middleware
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := UserFrom(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": http.StatusText(http.StatusUnauthorized)})
+			return
+		}
+		have := auth.RolesInContext(c.Request.Context(), user)
+		for _, r := range roles {
+			if have.Has(r) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": http.StatusText(http.StatusForbidden)})
+	}
+}
+
+// Signin write user encoded string into cookie
+func (ga *Auth) Signin(c *gin.Context, user auth.Encoder) error {
+	return ga.a.Signin(user, c.Writer)
+}
+
+// Signout setcookie with empty
+func (ga *Auth) Signout(c *gin.Context) {
+	ga.a.Signout(c.Writer)
+}
@@ -0,0 +1,117 @@
+package ginauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	auth "github.com/liut/simpauth"
+)
+
+func newEngine(t *testing.T, ga *Auth, redir bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/login", func(c *gin.Context) {
+		user := &auth.User{UID: c.PostForm("uid")}
+		user.Refresh()
+		assert.Nil(t, ga.Signin(c, user))
+	})
+	r.POST("/logout", func(c *gin.Context) { ga.Signout(c) })
+	me := func(c *gin.Context) {
+		user, ok := UserFrom(c)
+		assert.True(t, ok)
+		fromCtx, ok := auth.UserFromContext(c.Request.Context())
+		assert.True(t, ok)
+		assert.Equal(t, user, fromCtx)
+		c.String(http.StatusOK, user.UID)
+	}
+	r.GET("/me", ga.MiddlewareWordy(redir), me)
+	r.GET("/admin", ga.Middleware(), RequireRoles("admin"), me)
+	return r
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestGinAuth(t *testing.T) {
+	ga := New(auth.New(auth.WithCookie("_sess", "/", "example.net")))
+	r := newEngine(t, ga, false)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.net/login", nil)
+	req.PostForm = map[string][]string{"uid": {"alice"}}
+	ck := serve(r, req).Result().Cookies()[0]
+	assert.Equal(t, "_sess", ck.Name)
+	assert.Equal(t, "example.net", ck.Domain)
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.net/me", nil)
+	req.AddCookie(ck)
+	w := serve(r, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+
+	w = serve(r, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	var body map[string]string
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, auth.ErrNoTokenInRequest.Error(), body["error"])
+
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.AddCookie(ck)
+	assert.Equal(t, http.StatusForbidden, serve(r, req).Code)
+
+	root := &auth.User{UID: "root", Roles: auth.Names{"admin"}}
+	root.Refresh()
+	token, _ := root.Encode()
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, "root", serve(r, req).Body.String())
+
+	out := serve(r, httptest.NewRequest(http.MethodPost, "/logout", nil)).Result().Cookies()[0]
+	assert.Equal(t, "_sess", out.Name)
+	assert.Equal(t, -1, out.MaxAge)
+}
+
+func TestGinRedirectRefresh(t *testing.T) {
+	ga := New(auth.New(auth.WithURI("/login"), auth.WithRefreshHeader("", auth.RefreshHeader)))
+	r := newEngine(t, ga, true)
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+
+	old := &auth.User{UID: "bob", LastHit: time.Now().Add(-50 * time.Minute).Unix()}
+	token, _ := old.Encode()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = serve(r, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("X-Refreshed-Token"))
+}
+
+func TestGinTenantRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	u := &auth.User{UID: "alice", Roles: auth.Names{"viewer"}}
+	u.SetTeamRoles(2, "admin")
+	r := gin.New()
+	r.GET("/t/:tid", func(c *gin.Context) {
+		ctx := context.Background()
+		if c.Param("tid") == "2" {
+			ctx = auth.ContextWithTenant(ctx, 2)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(UserKey, u)
+	}, RequireRoles("admin"), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	assert.Equal(t, http.StatusNoContent, serve(r, httptest.NewRequest(http.MethodGet, "/t/2", nil)).Code)
+	assert.Equal(t, http.StatusForbidden, serve(r, httptest.NewRequest(http.MethodGet, "/t/1", nil)).Code)
+}
@@ -5,11 +5,13 @@ go 1.25
 require (
 	github.com/stretchr/testify v1.10.0
 	github.com/tinylib/msgp v1.2.5
+	golang.org/x/crypto v0.45.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/philhofer/fwd v1.1.3-0.20240916144458-20a13a1f6b7c // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
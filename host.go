@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// vars
+var (
+	ErrUnknownHost  = errors.New("no authorizer for host")
+	ErrCookieDomain = errors.New("host is out of the cookie domain")
+)
+
+// HostRouter pick an Authorizer by request host, for one binary serving
+// multiple domains with different cookies and lifetimes
+type HostRouter struct {
+	exact    map[string]Authorizer
+	wildcard []hostRoute // longest suffix first
+	fallback Authorizer
+}
+
+type hostRoute struct {
+	suffix string
+	a      Authorizer
+}
+
+// NewHostRouter return a HostRouter, fallback may be nil to reject unknown hosts
+func NewHostRouter(fallback Authorizer) *HostRouter {
+	return &HostRouter{exact: make(map[string]Authorizer), fallback: fallback}
+}
+
+// Handle use a for hosts of pattern, e.g. admin.example.com, or *.example.com for any subdomain.
+// It panics if pattern is invalid or its hosts are out of the cookie domain of a, like http.ServeMux
+func (hr *HostRouter) Handle(pattern string, a Authorizer) *HostRouter {
+	pattern = strings.ToLower(pattern)
+	host, wild := strings.CutPrefix(pattern, "*.")
+	if len(host) == 0 || strings.Contains(host, "*") {
+		panic("simpauth: invalid host pattern " + pattern)
+	}
+	if opt, ok := a.(*option); ok && !inCookieDomain(host, opt.CookieDomain) {
+		panic("simpauth: host pattern " + pattern + " is out of the cookie domain " + opt.CookieDomain)
+	}
+	if wild {
+		hr.wildcard = append(hr.wildcard, hostRoute{suffix: "." + host, a: a})
+		sort.SliceStable(hr.wildcard, func(i, j int) bool {
+			return len(hr.wildcard[i].suffix) > len(hr.wildcard[j].suffix)
+		})
+		return hr
+	}
+	hr.exact[pattern] = a
+	return hr
+}
+
+// Match return the Authorizer of host without port
+func (hr *HostRouter) Match(host string) (Authorizer, bool) {
+	host = strings.ToLower(host)
+	if a, ok := hr.exact[host]; ok {
+		return a, true
+	}
+	for _, w := range hr.wildcard {
+		if strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) {
+			return w.a, true
+		}
+	}
+	if hr.fallback != nil {
+		return hr.fallback, true
+	}
+	return nil, false
+}
+
+// ForRequest return the Authorizer of request host, the fallback is checked against
+// its cookie domain here, patterns in Handle
+func (hr *HostRouter) ForRequest(r *http.Request) (Authorizer, error) {
+	host := hostname(r)
+	a, ok := hr.Match(host)
+	if !ok {
+		slog.Info("unknown host", "host", host)
+		return nil, ErrUnknownHost
+	}
+	if opt, ok := a.(*option); ok && !inCookieDomain(host, opt.CookieDomain) {
+		slog.Info("host out of cookie domain", "host", host, "domain", opt.CookieDomain)
+		return nil, ErrCookieDomain
+	}
+	return a, nil
+}
+
+// Middleware ...
+func (hr *HostRouter) Middleware() func(next http.Handler) http.Handler {
+	return hr.MiddlewareWordy(false)
+}
+
+// MiddlewareWordy delegate to the MiddlewareWordy of request host
+func (hr *HostRouter) MiddlewareWordy(redir bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			a, err := hr.ForRequest(req)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusMisdirectedRequest)
+				return
+			}
+			a.MiddlewareWordy(redir)(next).ServeHTTP(rw, req)
+		})
+	}
+}
+
+// UserFromRequest ...
+func (hr *HostRouter) UserFromRequest(r *http.Request) (*User, error) {
+	a, err := hr.ForRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return a.UserFromRequest(r)
+}
+
+// Signin write cookie by the Authorizer of request host
+func (hr *HostRouter) Signin(user Encoder, w http.ResponseWriter, r *http.Request) error {
+	a, err := hr.ForRequest(r)
+	if err != nil {
+		return err
+	}
+	return a.Signin(user, w)
+}
+
+// Signout clear cookie by the Authorizer of request host
+func (hr *HostRouter) Signout(w http.ResponseWriter, r *http.Request) {
+	if a, err := hr.ForRequest(r); err == nil {
+		a.Signout(w)
+	}
+}
+
+// hostname return the lower-case host of request without port
+func hostname(r *http.Request) string {
+	host := r.Host
+	if i := strings.LastIndexByte(host, ':'); i > 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	return strings.ToLower(host)
+}
+
+// inCookieDomain check host can receive a cookie of domain, empty domain is host-only
+func inCookieDomain(host, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if len(domain) == 0 {
+		return true
+	}
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostRouter(t *testing.T) {
+	admin := New(WithCookie("_admin", "/", "admin.example.com"), WithMaxAge(600))
+	app := New(WithCookie("_app", "/", "example.com"))
+	hr := NewHostRouter(nil).
+		Handle("admin.example.com", admin).
+		Handle("*.example.com", app).
+		Handle("*.eu.example.com", app)
+
+	a, ok := hr.Match("admin.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, admin, a)
+	a, _ = hr.Match("App.Example.com")
+	assert.Equal(t, app, a)
+	a, _ = hr.Match("x.eu.example.com")
+	assert.Equal(t, app, a)
+	_, ok = hr.Match("example.com")
+	assert.False(t, ok)
+	_, ok = hr.Match("evil.net")
+	assert.False(t, ok)
+
+	// wildcards need a dot, hosts out of the cookie domain are refused when registered
+	assert.Panics(t, func() { NewHostRouter(nil).Handle("*example.com", app) })
+	assert.Panics(t, func() { NewHostRouter(nil).Handle("*", app) })
+	assert.Panics(t, func() { NewHostRouter(nil).Handle("*.example.com", admin) })
+	assert.Panics(t, func() { NewHostRouter(nil).Handle("evil.net", app) })
+	_, ok = NewHostRouter(nil).Handle("*.example.com", app).Match("evilexample.com")
+	assert.False(t, ok)
+
+	signin := func(host string) (*http.Cookie, error) {
+		req := httptest.NewRequest(http.MethodPost, "http://"+host+"/login", nil)
+		w := httptest.NewRecorder()
+		user := &User{UID: "alice"}
+		user.Refresh()
+		if err := hr.Signin(user, w, req); err != nil {
+			return nil, err
+		}
+		return w.Result().Cookies()[0], nil
+	}
+	ck, err := signin("admin.example.com:8443")
+	assert.Nil(t, err)
+	assert.Equal(t, "_admin", ck.Name)
+	assert.Equal(t, "admin.example.com", ck.Domain)
+	assert.Equal(t, 600, ck.MaxAge)
+
+	ck, err = signin("app.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "_app", ck.Name)
+	assert.Equal(t, "example.com", ck.Domain)
+
+	_, err = signin("evil.net")
+	assert.Equal(t, ErrUnknownHost, err)
+
+	// never set a cookie with a wrong domain by the fallback
+	req := httptest.NewRequest(http.MethodPost, "http://evil.net/login", nil)
+	assert.Equal(t, ErrCookieDomain, NewHostRouter(admin).Signin(&User{UID: "alice"}, httptest.NewRecorder(), req))
+
+	h := hr.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := UserFromContext(r.Context())
+		_, _ = io.WriteString(w, user.UID)
+	}))
+	do := func(host string, ck *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+		req.AddCookie(ck)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, "alice", do("www.example.com", ck).Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do("admin.example.com", ck).Code)
+	assert.Equal(t, http.StatusMisdirectedRequest, do("evil.net", ck).Code)
+
+	req = httptest.NewRequest(http.MethodGet, "http://www.example.com/", nil)
+	w := httptest.NewRecorder()
+	hr.Signout(w, req)
+	ck = w.Result().Cookies()[0]
+	assert.Equal(t, "_app", ck.Name)
+	assert.Equal(t, -1, ck.MaxAge)
+}
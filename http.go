@@ -2,10 +2,11 @@ package auth
 
 import (
 	"errors"
-	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"strings"
+	"time"
 )
 
 // Authorizer ...
@@ -28,6 +29,10 @@ var (
 	dftOpt *option
 
 	_ Authorizer = (*option)(nil)
+	_ tokenCodec = (*option)(nil)
+	_ binder     = (*option)(nil)
+	_ ipResolver = (*option)(nil)
+	_ stepper    = (*option)(nil)
 )
 
 func init() {
@@ -36,18 +41,32 @@ func init() {
 		CookiePath:   "/",
 		CookieMaxAge: 3600,
 		ParamName:    "token",
+		RefreshName:  "X-Refreshed-Token",
 	}
 }
 
 // option ...
 type option struct {
 	URI          string // redirect URI
+	StepURI      string // redirect URI for step-up authentication
 	Refresh      bool   // need Refresh
+	RefreshMode  RefreshMode
+	RefreshName  string // response header of refreshed token
+	Policy       RefreshPolicy
+	Audit        ImpersonationAudit
 	CookieName   string
 	CookiePath   string
 	CookieDomain string
 	CookieMaxAge int
 	ParamName    string
+	Revoker      Revoker
+	Binding      Binding
+	BindMode     BindMode
+	OnMismatch   BindingHook
+	DPoP         *DPoP
+	CertMapper   CertMapper
+	Proxies      []netip.Prefix
+	Secrets      [][]byte // HMAC keys, the first signs
 }
 
 func (opt *option) setDefaults() {
@@ -60,6 +79,9 @@ func (opt *option) setDefaults() {
 	if len(opt.ParamName) == 0 {
 		opt.ParamName = dftOpt.ParamName
 	}
+	if len(opt.RefreshName) == 0 {
+		opt.RefreshName = dftOpt.RefreshName
+	}
 }
 
 func (opt *option) With(opts ...OptFunc) {
@@ -80,6 +102,15 @@ func WithURI(uri string) OptFunc {
 	}
 }
 
+// WithStepURI The option with redirect uri of step-up authentication (e.g. MFA)
+func WithStepURI(uri string) OptFunc {
+	return func(opt *option) {
+		if len(uri) > 0 {
+			opt.StepURI = uri
+		}
+	}
+}
+
 // WithRefresh The option with auto refresh
 func WithRefresh() OptFunc {
 	return func(opt *option) {
@@ -87,6 +118,37 @@ func WithRefresh() OptFunc {
 	}
 }
 
+// RefreshMode where a refreshed token is emitted
+type RefreshMode int
+
+// refresh modes
+const (
+	RefreshCookie RefreshMode = iota // cookie only, default
+	RefreshHeader                    // response header only
+	RefreshBoth                      // cookie and response header
+	RefreshAuto                      // response header if the token came from Authorization header, else cookie
+)
+
+// WithRefreshHeader The option emit refreshed token in response header name (e.g. X-Refreshed-Token,
+// Authorization) with mode, implies WithRefresh
+func WithRefreshHeader(name string, mode RefreshMode) OptFunc {
+	return func(opt *option) {
+		opt.Refresh = true
+		opt.RefreshMode = mode
+		if len(name) > 0 {
+			opt.RefreshName = name
+		}
+	}
+}
+
+// WithRefreshPolicy The option decide refresh with a RefreshPolicy, implies WithRefresh
+func WithRefreshPolicy(p RefreshPolicy) OptFunc {
+	return func(opt *option) {
+		opt.Refresh = true
+		opt.Policy = p
+	}
+}
+
 // WithCookie set cookie 1-3 options: name, path, domain, see also http.Cookie
 func WithCookie(name string, args ...string) OptFunc {
 	return func(opt *option) {
@@ -115,6 +177,13 @@ func WithMaxAge(age int) OptFunc {
 	}
 }
 
+// WithRevoker set a Revoker, revoked tokens are rejected by UserFromRequest
+func WithRevoker(r Revoker) OptFunc {
+	return func(opt *option) {
+		opt.Revoker = r
+	}
+}
+
 // NewOption ..., Deprecated: use New()
 func NewOption(opts ...OptFunc) Authorizer {
 	return New(opts...)
@@ -155,19 +224,115 @@ func (opt *option) MiddlewareWordy(redir bool) func(next http.Handler) http.Hand
 		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 			user, err := opt.UserFromRequest(req)
 			if err != nil {
-				if redir && opt.URI != "" {
-					http.Redirect(rw, req, opt.URI, http.StatusFound)
-				} else {
+				opt.deny(rw, req, err, redir)
+				return
+			}
+			opt.serveUser(rw, req, user, next)
+		})
+	}
+}
+
+// deny redirect to URI if redir, otherwise response 401
+func (opt *option) deny(rw http.ResponseWriter, req *http.Request, err error, redir bool) {
+	if redir && opt.URI != "" {
+		storeReturn(rw, req)
+		http.Redirect(rw, req, opt.URI, http.StatusFound)
+	} else {
+		http.Error(rw, err.Error(), http.StatusUnauthorized)
+	}
+}
+
+// serveUser audit and refresh an authenticated user, then call next with user in context
+func (opt *option) serveUser(rw http.ResponseWriter, req *http.Request, user *User, next http.Handler) {
+	if user.IsImpersonated() {
+		opt.auditImpersonated(req, user)
+	}
+	// refresh before next, headers are never written after the handler started
+	if opt.needRefresh(user) {
+		fresh := *user
+		fresh.Refresh()
+		_, src := opt.tokenFrom(req.Header, req)
+		opt.emitRefresh(rw, &fresh, src)
+	}
+
+	req = req.WithContext(ContextWithUser(req.Context(), user))
+	next.ServeHTTP(rw, req)
+}
+
+// needRefresh decide by Policy for *User, otherwise by user itself
+func (opt *option) needRefresh(user Refresher) bool {
+	if !opt.Refresh {
+		return false
+	}
+	if u, ok := user.(*User); ok && opt.Policy != nil {
+		return opt.Policy.NeedRefresh(u, time.Now())
+	}
+	return user.NeedRefresh()
+}
+
+// emitRefresh write refreshed token into cookie and/or response header by RefreshMode
+func (opt *option) emitRefresh(rw http.ResponseWriter, user Encoder, src tokenSource) {
+	value, err := opt.encodeToken(user)
+	if err != nil {
+		slog.Info("encode fail", "err", err)
+		return
+	}
+	mode := opt.RefreshMode
+	if mode == RefreshAuto {
+		mode = RefreshCookie
+		if src == sourceHeader {
+			mode = RefreshHeader
+		}
+	}
+	if mode == RefreshCookie || mode == RefreshBoth {
+		http.SetCookie(rw, opt.Cooking(value))
+	}
+	if mode == RefreshHeader || mode == RefreshBoth {
+		if strings.EqualFold(opt.RefreshName, "Authorization") {
+			value = "Bearer " + value
+		}
+		rw.Header().Set(opt.RefreshName, value)
+	}
+}
+
+// stepper redirect to step-up authentication, implemented by authorizers of this package
+type stepper interface {
+	stepURI() string
+}
+
+func (opt *option) stepURI() string {
+	return opt.StepURI
+}
+
+// RequireFreshAuth require the last authentication of user within maxAge and with all methods,
+// otherwise redirect to StepURI of a, use after Middleware. It needs signed tokens (WithSecret),
+// requests are rejected without
+func RequireFreshAuth(a Authorizer, maxAge time.Duration, methods ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if _, err := codecOf(a, true); err != nil {
+				slog.Warn("step-up auth without signed tokens", "err", err)
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			user, ok := UserFromContext(req.Context())
+			if !ok {
+				var err error
+				if user, err = a.UserFromRequest(req); err != nil {
 					http.Error(rw, err.Error(), http.StatusUnauthorized)
+					return
 				}
-				return
 			}
-			if opt.Refresh && user.NeedRefresh() {
-				user.Refresh()
-				_ = opt.Signin(user, rw)
+			if !user.IsFreshAuth(maxAge, methods...) {
+				slog.Info("need step-up auth", "uid", user.UID, "amr", user.AuthMeths, "at", user.AuthTime)
+				if st, ok := a.(stepper); ok && st.stepURI() != "" {
+					storeReturn(rw, req)
+					http.Redirect(rw, req, st.stepURI(), http.StatusFound)
+				} else {
+					http.Error(rw, "authentication is too old", http.StatusUnauthorized)
+				}
+				return
 			}
-
-			req = req.WithContext(ContextWithUser(req.Context(), user))
 			next.ServeHTTP(rw, req)
 		})
 	}
@@ -185,26 +350,55 @@ func UserFromRequest(r *http.Request) (user *User, err error) {
 
 // UserFromRequest get user from cookie
 func (opt *option) UserFromRequest(r *http.Request) (user *User, err error) {
+	if len(opt.Proxies) > 0 {
+		if user = opt.userFromProxy(r); user != nil {
+			return
+		}
+	}
+	if (opt.Binding != 0 || opt.DPoP != nil) && !opt.signed() {
+		slog.Warn("binding without signed tokens", "err", ErrNoSecret)
+		return nil, ErrNoSecret
+	}
 	var token string
 	token, err = opt.TokenFromRequest(r)
+	if err == ErrNoTokenInRequest && opt.CertMapper != nil {
+		return opt.userFromCert(r)
+	}
 	if err != nil {
 		slog.Info("no token in req", "cn", opt.CookieName, "err", err)
 		return
 	}
-	user = new(User)
-	err = user.Decode(token)
-	if err != nil {
-		slog.Info("decode fail", "token", token, "err", err)
+	if user, err = opt.parseToken(token); err != nil {
+		return
+	}
+	if !user.AllowPath(r.URL.Path) {
+		slog.Info("path restricted", "uid", user.UID, "path", r.URL.Path, "allow", user.Path)
+		err = ErrPathRestricted
 		return
 	}
-	if user.IsExpired() {
-		slog.Info("expired", "token", token, "uid", user.UID)
-		err = fmt.Errorf("user %s is expired", user.UID)
+	// JKT is trusted only if signed, a token sent with the DPoP scheme must be bound
+	if len(user.JKT) > 0 || dpopScheme(r.Header) {
+		if err = opt.checkDPoP(r, user, token); err != nil {
+			return
+		}
+	}
+	if opt.Binding != 0 {
+		err = opt.checkBinding(r, user)
 	}
 	// slog.Debug("got usr from req", "user", user)
 	return
 }
 
+// validToken get a token from request, check revocation and signature, return the payload
+func (opt *option) validToken(r *http.Request) (payload string, err error) {
+	token, err := opt.TokenFromRequest(r)
+	if err != nil {
+		slog.Info("no token in req", "cn", opt.CookieName, "err", err)
+		return
+	}
+	return opt.checkToken(token)
+}
+
 // TokenFromRequest get a token from request
 func (opt *option) TokenFromRequest(req *http.Request) (s string, err error) {
 	s = opt.TokenFrom(req.Header, req)
@@ -241,32 +435,66 @@ func TokenFrom(args ...any) string {
 // TokenFrom return token string
 // valid interfaces: *http.Request, Request.Header, *fiber.Ctx
 func (opt *option) TokenFrom(args ...any) string {
+	s, _ := opt.tokenFrom(args...)
+	return s
+}
+
+// tokenSource where a token comes from
+type tokenSource int
+
+// token sources
+const (
+	sourceNone tokenSource = iota
+	sourceHeader
+	sourceCookie
+	sourceParam
+)
+
+func (opt *option) tokenFrom(args ...any) (string, tokenSource) {
 	for _, arg := range args {
 		if v, ok := arg.(Getter); ok { // request.Header, fiber.Ctx
-			if ah := v.Get("Authorization"); len(ah) > 6 && strings.ToUpper(ah[0:6]) == "BEARER" {
-				return ah[7:]
+			if s := bearerToken(v); s != "" {
+				return s, sourceHeader
 			}
 		}
 
 		if v, ok := arg.(Cookier); ok { // request
 			if ck, err := v.Cookie(opt.CookieName); err == nil && ck.Value != "" {
-				return ck.Value
+				return ck.Value, sourceCookie
 			}
 		}
 		if v, ok := arg.(cookieser); ok { // fiber.Ctx
 			if s := v.Cookies(opt.CookieName); s != "" {
-				return s
+				return s, sourceCookie
 			}
 		}
 		if v, ok := arg.(FormValuer); ok { // request form, fiber.Ctx
 			if s := v.FormValue(opt.ParamName); s != "" {
-				return s
+				return s, sourceParam
 			}
 		}
 	}
+	return "", sourceNone
+}
+
+// bearerToken return the token in Authorization header, scheme Bearer or DPoP
+func bearerToken(v Getter) string {
+	ah := v.Get("Authorization")
+	if len(ah) > 6 && strings.ToUpper(ah[0:6]) == "BEARER" {
+		return ah[7:]
+	}
+	if len(ah) > 5 && strings.ToUpper(ah[0:5]) == "DPOP " {
+		return ah[5:]
+	}
 	return ""
 }
 
+// dpopScheme checks if the Authorization header is of scheme DPoP
+func dpopScheme(v Getter) bool {
+	ah := v.Get("Authorization")
+	return len(ah) > 5 && strings.ToUpper(ah[0:5]) == "DPOP "
+}
+
 // Signin call Signin for login
 func (user *User) Signin(w http.ResponseWriter) error {
 	return Signin(user, w)
@@ -282,9 +510,9 @@ func Signin(user Encoder, w http.ResponseWriter) error {
 	return dftOpt.Signin(user, w)
 }
 
-// Signin write user encoded string into cookie
+// Signin write user encoded string into cookie, signed if WithSecret
 func (opt *option) Signin(user Encoder, w http.ResponseWriter) error {
-	value, err := user.Encode()
+	value, err := opt.encodeToken(user)
 	if err != nil {
 		slog.Info("encode fail", "err", err)
 		return err
@@ -300,6 +528,7 @@ func (opt *option) Cooking(value string) *http.Cookie {
 		Value:    value,
 		MaxAge:   opt.CookieMaxAge,
 		Path:     opt.CookiePath,
+		Domain:   opt.CookieDomain,
 		HttpOnly: true,
 	}
 }
@@ -309,13 +538,24 @@ func Signout(w http.ResponseWriter) {
 	dftOpt.Signout(w)
 }
 
-// Signout setcookie with empty
+// Signout setcookie with empty, with a domain also expire the host-only cookie,
+// which was set before the domain is configured
 func (opt *option) Signout(w http.ResponseWriter) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     opt.CookieName,
 		Value:    "",
 		MaxAge:   -1,
 		Path:     opt.CookiePath,
+		Domain:   opt.CookieDomain,
 		HttpOnly: true,
 	})
+	if len(opt.CookieDomain) > 0 {
+		http.SetCookie(w, &http.Cookie{
+			Name:     opt.CookieName,
+			Value:    "",
+			MaxAge:   -1,
+			Path:     opt.CookiePath,
+			HttpOnly: true,
+		})
+	}
 }
@@ -3,6 +3,7 @@ package auth
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -106,3 +107,43 @@ func TestHTTPBack(t *testing.T) {
 	}
 
 }
+
+func TestRefreshHeader(t *testing.T) {
+	var user = &User{UID: "testUID"}
+	user.LastHit = time.Now().Unix() - DefaultLifetime*2/3
+	token, _ := user.Encode()
+
+	do := func(opt Authorizer, fromHeader bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if fromHeader {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else {
+			req.AddCookie(opt.Cooking(token))
+		}
+		w := httptest.NewRecorder()
+		opt.Middleware()(http.NotFoundHandler()).ServeHTTP(w, req)
+		return w
+	}
+
+	opt := New(WithRefreshHeader("", RefreshAuto))
+	w := do(opt, true)
+	if w.Header().Get("X-Refreshed-Token") == "" || w.Header().Get("Set-Cookie") != "" {
+		t.Fatalf("want header only, got %v", w.Header())
+	}
+	w = do(opt, false)
+	if w.Header().Get("X-Refreshed-Token") != "" || w.Header().Get("Set-Cookie") == "" {
+		t.Fatalf("want cookie only, got %v", w.Header())
+	}
+
+	opt = New(WithRefreshHeader("Authorization", RefreshBoth))
+	w = do(opt, false)
+	if !strings.HasPrefix(w.Header().Get("Authorization"), "Bearer ") || w.Header().Get("Set-Cookie") == "" {
+		t.Fatalf("want both, got %v", w.Header())
+	}
+
+	// default: cookie only
+	w = do(New(WithRefresh()), true)
+	if w.Header().Get("X-Refreshed-Token") != "" || w.Header().Get("Set-Cookie") == "" {
+		t.Fatalf("want cookie only, got %v", w.Header())
+	}
+}
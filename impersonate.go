@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// vars
+var (
+	ErrAlreadyImpersonating = errors.New("already impersonating")
+	ErrNotImpersonating     = errors.New("not impersonating")
+)
+
+// ImpersonationAudit called for every request of an impersonated user
+type ImpersonationAudit func(r *http.Request, user *User)
+
+// WithImpersonationAudit The option with a hook of impersonated requests,
+// they are always logged with slog
+func WithImpersonationAudit(fn ImpersonationAudit) OptFunc {
+	return func(opt *option) {
+		opt.Audit = fn
+	}
+}
+
+// StartImpersonation signin as target with actor kept in the token, check the
+// permission of actor before calling. It requires WithSecret of a, the actor is
+// restored from the signed token only
+func StartImpersonation(a Authorizer, w http.ResponseWriter, actor *User, target IUser) error {
+	if _, err := codecOf(a, true); err != nil {
+		return err
+	}
+	if actor.IsImpersonated() {
+		return ErrAlreadyImpersonating
+	}
+	origin, err := actor.Encode()
+	if err != nil {
+		return err
+	}
+	user := ToUser(target)
+	user.Actor = &Actor{OID: actor.OID, UID: actor.UID, Name: actor.Name, Roles: actor.Roles}
+	user.Origin = origin
+	user.Bound = actor.Bound
+	user.Refresh()
+	slog.Info("start impersonation", "actor", actor.UID, "uid", user.UID)
+	return a.Signin(&user, w)
+}
+
+// StopImpersonation signin back as the original user with all claims
+func StopImpersonation(a Authorizer, w http.ResponseWriter, r *http.Request) error {
+	if _, err := codecOf(a, true); err != nil {
+		return err
+	}
+	user, err := a.UserFromRequest(r)
+	if err != nil {
+		return err
+	}
+	if !user.IsImpersonated() || len(user.Origin) == 0 {
+		return ErrNotImpersonating
+	}
+	back := new(User)
+	if err = back.Decode(user.Origin); err != nil {
+		return err
+	}
+	if back.UID != user.Actor.UID || back.IsImpersonated() {
+		return ErrNotImpersonating
+	}
+	back.Refresh()
+	slog.Info("stop impersonation", "actor", back.UID, "uid", user.UID)
+	return a.Signin(back, w)
+}
+
+// auditImpersonated ...
+func (opt *option) auditImpersonated(r *http.Request, user *User) {
+	slog.Info("impersonated request", "actor", user.Actor.UID, "uid", user.UID,
+		"method", r.Method, "path", r.URL.Path)
+	if opt.Audit != nil {
+		opt.Audit(r, user)
+	}
+}
+
+// ActorFromContext return the original user if the user in context is impersonated
+func ActorFromContext(ctx context.Context) (*Actor, bool) {
+	if user, ok := UserFromContext(ctx); ok && user.Actor != nil {
+		return user.Actor, true
+	}
+	return nil, false
+}
+
+// DenyImpersonation block sensitive endpoints while impersonating, use after Middleware
+func DenyImpersonation() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if actor, ok := ActorFromContext(req.Context()); ok {
+				slog.Info("denied while impersonating", "actor", actor.UID, "path", req.URL.Path)
+				http.Error(rw, "not allowed while impersonating", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
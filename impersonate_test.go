@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpersonation(t *testing.T) {
+	var audited []string
+	a := New(WithSecret(testSecret), WithImpersonationAudit(func(r *http.Request, u *User) {
+		audited = append(audited, u.Actor.UID+">"+u.UID+" "+r.URL.Path)
+	}))
+	staff := &User{UID: "staff", Name: "Staff", Roles: Names{"support"}, TeamID: 3, Teams: Teams{{TeamID: 4, Roles: Names{"ops"}}}}
+	staff.Authenticated(AmrPassword, AmrOTP)
+	customer := mockUser{uid: "cust", name: "Customer"}
+
+	assert.Equal(t, ErrNoSecret, StartImpersonation(New(), httptest.NewRecorder(), staff, customer))
+
+	w := httptest.NewRecorder()
+	assert.Nil(t, StartImpersonation(a, w, staff, customer))
+	ck := w.Result().Cookies()[0]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		user, _ := UserFromContext(r.Context())
+		assert.Equal(t, "cust", user.UID)
+		actor, ok := ActorFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "staff", actor.UID)
+		assert.Equal(t, Names{"support"}, actor.Roles)
+	})
+	mux.Handle("/password", DenyImpersonation()(http.NotFoundHandler()))
+	h := a.Middleware()(mux)
+
+	do := func(path string, ck *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(ck)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, do("/home", ck))
+	assert.Equal(t, http.StatusForbidden, do("/password", ck))
+	assert.Equal(t, []string{"staff>cust /home", "staff>cust /password"}, audited)
+
+	// nested impersonation
+	imp, err := a.(*option).parseToken(ck.Value)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrAlreadyImpersonating, StartImpersonation(a, httptest.NewRecorder(), imp, customer))
+
+	// a forged actor is not accepted
+	forged := *imp
+	forged.Actor = &Actor{UID: "root", Roles: Names{"admin"}}
+	token, _ := forged.Encode()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(a.Cooking(token))
+	assert.Equal(t, ErrInvalidSignature, StopImpersonation(a, httptest.NewRecorder(), req))
+
+	// stop
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(ck)
+	w = httptest.NewRecorder()
+	assert.Nil(t, StopImpersonation(a, w, req))
+	back := w.Result().Cookies()[0]
+	u, err := a.(*option).parseToken(back.Value)
+	assert.Nil(t, err)
+	assert.Equal(t, "staff", u.UID)
+	assert.False(t, u.IsImpersonated())
+	assert.Equal(t, int64(3), u.TeamID)
+	assert.Equal(t, staff.Teams, u.Teams)
+	assert.Equal(t, staff.AuthMeths, u.AuthMeths)
+	assert.Equal(t, staff.AuthTime, u.AuthTime)
+	assert.Equal(t, http.StatusNotFound, do("/password", back))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(back)
+	assert.Equal(t, ErrNotImpersonating, StopImpersonation(a, httptest.NewRecorder(), req))
+}
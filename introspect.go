@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInactiveToken ...
+var ErrInactiveToken = errors.New("token is not active")
+
+// Introspection the response of introspection endpoint, see RFC 7662
+type Introspection struct {
+	Active   bool   `json:"active"`
+	Sub      string `json:"sub,omitempty"`
+	Username string `json:"username,omitempty"`
+	Exp      int64  `json:"exp,omitempty"`
+	Iat      int64  `json:"iat,omitempty"`
+	Roles    Names  `json:"roles,omitempty"`
+	TeamID   int64  `json:"tid,omitempty"`
+	Scope    string `json:"scope,omitempty"`     // space separated
+	ClientID string `json:"client_id,omitempty"` // empty for first-party
+	Path     string `json:"path,omitempty"`      // path prefix the token is restricted to
+	Act      *Actor `json:"act,omitempty"`       // the original user while impersonating
+	Cnf      *Cnf   `json:"cnf,omitempty"`       // the DPoP key the token is bound to
+}
+
+// Cnf the confirmation claim of RFC 7800, with jkt of RFC 9449
+type Cnf struct {
+	JKT string `json:"jkt,omitempty"`
+}
+
+// ToUser ...
+func (in *Introspection) ToUser() *User {
+	var jkt string
+	if in.Cnf != nil {
+		jkt = in.Cnf.JKT
+	}
+	return &User{
+		UID:     in.Sub,
+		Name:    in.Username,
+		LastHit: in.Iat,
+		TeamID:  in.TeamID,
+		Roles:   in.Roles,
+		Scopes:  Names(strings.Fields(in.Scope)),
+		Client:  in.ClientID,
+		Path:    in.Path,
+		Actor:   in.Act,
+		JKT:     jkt,
+	}
+}
+
+// IntrospectHandler the introspection endpoint, validate the signature, revocation and
+// expiry of a token, only confidential clients are allowed. Tokens are never active
+// if a has no secret (WithSecret), unsigned tokens prove nothing. The path restriction
+// is returned for the resource server to check, see Introspector
+func IntrospectHandler(a Authorizer, clients ClientStore) http.Handler {
+	tc, cerr := codecOf(a, true)
+	if cerr != nil {
+		slog.Warn("introspection without signed tokens", "err", cerr)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		client, err := authClient(clients, r)
+		if err == nil && client.Secret == "" {
+			err = oauthErr("invalid_client", "confidential client required")
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+			writeJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		res := &Introspection{}
+		token := r.PostFormValue("token")
+		if len(token) > 0 && tc != nil {
+			if user, err := tc.parseToken(token); err == nil {
+				res = &Introspection{
+					Active:   true,
+					Sub:      user.UID,
+					Username: user.Name,
+					Iat:      user.LastHit,
+					Exp:      user.ExpiresAt(),
+					Roles:    user.Roles,
+					TeamID:   user.TeamID,
+					Scope:    strings.Join(user.Scopes, " "),
+					ClientID: user.Client,
+					Path:     user.Path,
+					Act:      user.Actor,
+				}
+				if len(user.JKT) > 0 {
+					res.Cnf = &Cnf{JKT: user.JKT}
+				}
+			}
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, res)
+	})
+}
+
+type introspected struct {
+	res   *Introspection
+	until time.Time
+}
+
+// Introspector a client of remote introspection endpoint with response caching
+type Introspector struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+	TTL          time.Duration // cache lifetime of responses, default 1 minute
+	Client       *http.Client
+	DPoP         *DPoP // verify proofs of bound tokens, they are rejected without
+
+	mu    sync.Mutex
+	cache map[string]introspected
+}
+
+// NewIntrospector ...
+func NewIntrospector(uri, clientID, clientSecret string) *Introspector {
+	return &Introspector{
+		URL:          uri,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TTL:          time.Minute,
+		Client:       http.DefaultClient,
+		cache:        make(map[string]introspected),
+	}
+}
+
+// Introspect query the remote endpoint, cached results are never kept beyond exp
+func (ic *Introspector) Introspect(ctx context.Context, token string) (*Introspection, error) {
+	key := tokenHash(token)
+	now := time.Now()
+	ic.mu.Lock()
+	c, ok := ic.cache[key]
+	ic.mu.Unlock()
+	if ok && c.until.After(now) {
+		return c.res, nil
+	}
+
+	v := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ic.URL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(ic.ClientID), url.QueryEscape(ic.ClientSecret))
+	resp, err := ic.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspect: %s", resp.Status)
+	}
+	res := new(Introspection)
+	if err = json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return nil, err
+	}
+
+	until := now.Add(ic.TTL)
+	if res.Exp > 0 && time.Unix(res.Exp, 0).Before(until) {
+		until = time.Unix(res.Exp, 0)
+	}
+	ic.mu.Lock()
+	if len(ic.cache) > 1000 {
+		for k, c := range ic.cache {
+			if c.until.Before(now) {
+				delete(ic.cache, k)
+			}
+		}
+	}
+	ic.cache[key] = introspected{res: res, until: until}
+	ic.mu.Unlock()
+	return res, nil
+}
+
+// Middleware validate the Bearer token by Introspect and its path restriction,
+// and the DPoP proof of a bound token (see DPoP), put the User into context
+func (ic *Introspector) Middleware() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			token := bearerToken(req.Header)
+			if len(token) == 0 {
+				http.Error(rw, ErrNoTokenInRequest.Error(), http.StatusUnauthorized)
+				return
+			}
+			res, err := ic.Introspect(req.Context(), token)
+			if err != nil {
+				slog.Info("introspect fail", "err", err)
+				http.Error(rw, err.Error(), http.StatusBadGateway)
+				return
+			}
+			if !res.Active {
+				http.Error(rw, ErrInactiveToken.Error(), http.StatusUnauthorized)
+				return
+			}
+			user := res.ToUser()
+			// a token sent with the DPoP scheme must be bound, see UserFromRequest
+			if len(user.JKT) > 0 || dpopScheme(req.Header) {
+				if err = checkDPoP(ic.DPoP, req, user, token); err != nil {
+					http.Error(rw, err.Error(), http.StatusUnauthorized)
+					return
+				}
+			}
+			if !user.AllowPath(req.URL.Path) {
+				http.Error(rw, ErrPathRestricted.Error(), http.StatusUnauthorized)
+				return
+			}
+			req = req.WithContext(ContextWithUser(req.Context(), user))
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
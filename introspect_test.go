@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntrospectHandler(t *testing.T) {
+	revoker := NewRevoker()
+	a := New(WithRevoker(revoker), WithSecret(testSecret))
+	clients := ClientMap{
+		"rs":  {ID: "rs", Secret: "s3cret"},
+		"spa": {ID: "spa"},
+	}
+	h := IntrospectHandler(a, clients)
+
+	user := &User{UID: "alice", Roles: Names{"admin"}, TeamID: 7}
+	user.Refresh()
+	token := signToken(t, a, user)
+
+	introspect := func(v url.Values) (int, *Introspection) {
+		w := postForm(h, v)
+		res := new(Introspection)
+		_ = json.Unmarshal(w.Body.Bytes(), res)
+		return w.Code, res
+	}
+
+	code, _ := introspect(url.Values{"client_id": {"spa"}, "token": {token}})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, res := introspect(url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {token}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, res.Active)
+	assert.Equal(t, "alice", res.Sub)
+	assert.Equal(t, Names{"admin"}, res.Roles)
+	assert.Equal(t, int64(7), res.TeamID)
+	assert.Equal(t, user.LastHit+DefaultLifetime, res.Exp)
+
+	// impersonation is kept
+	imp := &User{UID: "bob", Actor: &Actor{UID: "root", Roles: Names{"admin"}}}
+	imp.Refresh()
+	_, res = introspect(url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {signToken(t, a, imp)}})
+	assert.True(t, res.Active)
+	if assert.NotNil(t, res.Act) {
+		assert.Equal(t, "root", res.Act.UID)
+	}
+	assert.True(t, res.ToUser().IsImpersonated())
+
+	// the DPoP key binding is kept
+	bound := &User{UID: "mobile", JKT: "thumb"}
+	bound.Refresh()
+	_, res = introspect(url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {signToken(t, a, bound)}})
+	if assert.NotNil(t, res.Cnf) {
+		assert.Equal(t, "thumb", res.Cnf.JKT)
+	}
+	assert.Equal(t, "thumb", res.ToUser().JKT)
+
+	// forged by the client
+	forged, _ := user.Encode()
+	_, res = introspect(url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {forged}})
+	assert.False(t, res.Active)
+	user.Roles = Names{"root"}
+	forged, _ = user.Encode()
+	_, res = introspect(url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {forged + token[strings.LastIndexByte(token, '.'):]}})
+	assert.False(t, res.Active)
+
+	// never active without a secret
+	_, res = introspect(url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {token}})
+	assert.True(t, res.Active)
+	w := postForm(IntrospectHandler(New(), clients), url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {token}})
+	assert.JSONEq(t, `{"active":false}`, w.Body.String())
+
+	revoker.Revoke(token, time.Now().Add(time.Hour))
+	_, res = introspect(url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {token}})
+	assert.False(t, res.Active)
+
+	_, res = introspect(url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {"garbage"}})
+	assert.False(t, res.Active)
+}
+
+func TestIntrospector(t *testing.T) {
+	var calls atomic.Int32
+	a := New(WithSecret(testSecret))
+	h := IntrospectHandler(a, ClientMap{"rs": {ID: "rs", Secret: "s3cret"}})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		h.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	ic := NewIntrospector(ts.URL, "rs", "s3cret")
+	mw := ic.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := UserFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "alice", user.UID)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	user := &User{UID: "alice"}
+	user.Refresh()
+	token := signToken(t, a, user)
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer bad")
+	w := httptest.NewRecorder()
+	mw.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	mw.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// path restriction is checked by the resource server
+	child, _ := user.Narrow([]string{"read"}, time.Minute, "/api/")
+	token = signToken(t, a, child)
+	for path, status := range map[string]int{"/api/items": http.StatusNoContent, "/admin": http.StatusUnauthorized} {
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+
+	// bound tokens need a proof of the key
+	key := newDPoPKey(t)
+	bound := &User{UID: "alice", JKT: key.jwk.Thumbprint()}
+	bound.Refresh()
+	token = signToken(t, a, bound)
+	call := func(scheme, proof string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.net/items", nil)
+		req.Header.Set("Authorization", scheme+" "+token)
+		if proof != "" {
+			req.Header.Set("DPoP", proof)
+		}
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		return w.Code
+	}
+	proof := func() string {
+		return key.proof(t, http.MethodGet, "http://api.example.net/items", token, time.Now())
+	}
+	assert.Equal(t, http.StatusUnauthorized, call("DPoP", proof()))
+	ic.DPoP = NewDPoP(nil)
+	assert.Equal(t, http.StatusUnauthorized, call("Bearer", ""))
+	assert.Equal(t, http.StatusUnauthorized, call("DPoP", newDPoPKey(t).proof(t, http.MethodGet, "http://api.example.net/items", token, time.Now())))
+	assert.Equal(t, http.StatusNoContent, call("DPoP", proof()))
+
+	// the DPoP scheme with an unbound token
+	token = signToken(t, a, user)
+	assert.Equal(t, http.StatusUnauthorized, call("DPoP", proof()))
+}
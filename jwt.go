@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// vars
+var (
+	ErrInvalidJWT   = errors.New("invalid jwt")
+	ErrUnsupportAlg = errors.New("unsupported jwt alg")
+)
+
+// jwtHeader ...
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+	JWK *JWK   `json:"jwk,omitempty"`
+}
+
+// jwtToken a parsed but not verified JWS compact token
+type jwtToken struct {
+	Header jwtHeader
+	Claims map[string]any
+	input  string // signing input: header.payload
+	sig    []byte
+}
+
+func parseJWT(s string) (*jwtToken, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidJWT
+	}
+	b64 := base64.RawURLEncoding
+	t := &jwtToken{input: parts[0] + "." + parts[1]}
+	hb, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidJWT
+	}
+	if err = json.Unmarshal(hb, &t.Header); err != nil {
+		return nil, ErrInvalidJWT
+	}
+	pb, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidJWT
+	}
+	if err = json.Unmarshal(pb, &t.Claims); err != nil {
+		return nil, ErrInvalidJWT
+	}
+	if t.sig, err = b64.DecodeString(parts[2]); err != nil {
+		return nil, ErrInvalidJWT
+	}
+	return t, nil
+}
+
+// verify check signature with key, support RS256 and ES256
+func (t *jwtToken) verify(key crypto.PublicKey) error {
+	digest := sha256.Sum256([]byte(t.input))
+	switch t.Header.Alg {
+	case "RS256":
+		pk, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidJWT
+		}
+		if err := rsa.VerifyPKCS1v15(pk, crypto.SHA256, digest[:], t.sig); err != nil {
+			return ErrInvalidJWT
+		}
+	case "ES256":
+		pk, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(t.sig) != 64 {
+			return ErrInvalidJWT
+		}
+		r, s := new(big.Int).SetBytes(t.sig[:32]), new(big.Int).SetBytes(t.sig[32:])
+		if !ecdsa.Verify(pk, digest[:], r, s) {
+			return ErrInvalidJWT
+		}
+	default:
+		return ErrUnsupportAlg
+	}
+	return nil
+}
+
+// claimString ...
+func (t *jwtToken) claimString(k string) string {
+	s, _ := t.Claims[k].(string)
+	return s
+}
+
+// claimInt ...
+func (t *jwtToken) claimInt(k string) int64 {
+	f, _ := t.Claims[k].(float64)
+	return int64(f)
+}
+
+// JWK a public JSON Web Key, see RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// PublicKey return *rsa.PublicKey or *ecdsa.PublicKey
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	b64 := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, ErrUnsupportAlg
+		}
+		x, err := b64.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, ErrInvalidJWT
+		}
+		pk, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, err
+		}
+		return pk, nil
+	}
+	return nil, ErrUnsupportAlg
+}
+
+// JWKSet ...
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vars
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrLoginLocked        = errors.New("too many failed attempts, try later")
+
+	// ReturnCookieName the cookie keeps the URL before redirect to login
+	ReturnCookieName = "_return"
+
+	dummyHash = sync.OnceValue(func() string {
+		s, _ := HashPassword("")
+		return s
+	})
+)
+
+// UserLookup find a user and the password hash by login name
+type UserLookup interface {
+	LookupUser(ctx context.Context, uid string) (user IUser, hash string, err error)
+}
+
+// PasswordUpdater optional interface of UserLookup, used for rehash on login
+type PasswordUpdater interface {
+	UpdatePassword(ctx context.Context, uid, hash string) error
+}
+
+// Limiter count failed attempts and lock a key
+type Limiter interface {
+	Locked(key string) bool
+	Fail(key string)
+	Reset(key string)
+}
+
+type attempt struct {
+	fails []time.Time // within the window
+	until time.Time
+}
+
+// prune forget failures before since
+func (a *attempt) prune(since time.Time) {
+	i := 0
+	for i < len(a.fails) && a.fails[i].Before(since) {
+		i++
+	}
+	a.fails = a.fails[i:]
+}
+
+type memLimiter struct {
+	mu      sync.Mutex
+	max     int
+	lockout time.Duration
+	items   map[string]*attempt
+}
+
+// NewLimiter return an in-memory Limiter, lock a key for lockout after max failures
+// within a sliding window of the same duration
+func NewLimiter(max int, lockout time.Duration) Limiter {
+	return &memLimiter{max: max, lockout: lockout, items: make(map[string]*attempt)}
+}
+
+func (l *memLimiter) Locked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a, ok := l.items[key]; ok {
+		if a.until.After(time.Now()) {
+			return true
+		}
+		if !a.until.IsZero() {
+			delete(l.items, key)
+		}
+	}
+	return false
+}
+
+func (l *memLimiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	since := now.Add(-l.lockout)
+	if len(l.items) > 10000 {
+		for k, a := range l.items {
+			a.prune(since)
+			if len(a.fails) == 0 && a.until.Before(now) {
+				delete(l.items, k)
+			}
+		}
+	}
+	a, ok := l.items[key]
+	if !ok {
+		a = new(attempt)
+		l.items[key] = a
+	}
+	a.prune(since)
+	a.fails = append(a.fails, now)
+	if len(a.fails) >= l.max {
+		a.fails = nil
+		a.until = now.Add(l.lockout)
+	}
+}
+
+func (l *memLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.items, key)
+}
+
+// loginOption ...
+type loginOption struct {
+	UserField string
+	PassField string
+	Redirect  string
+	Limiter   Limiter
+}
+
+// LoginOptFunc ...
+type LoginOptFunc func(opt *loginOption)
+
+// WithLoginFields set form field names of username and password, default: username, password
+func WithLoginFields(user, pass string) LoginOptFunc {
+	return func(opt *loginOption) {
+		if len(user) > 0 {
+			opt.UserField = user
+		}
+		if len(pass) > 0 {
+			opt.PassField = pass
+		}
+	}
+}
+
+// WithLoginRedirect set the redirect URL after login if no return URL stored, default: /
+func WithLoginRedirect(uri string) LoginOptFunc {
+	return func(opt *loginOption) {
+		if len(uri) > 0 {
+			opt.Redirect = uri
+		}
+	}
+}
+
+// WithLimiter set the failed-attempt limiter, default: 5 failures lock 15 minutes
+func WithLimiter(l Limiter) LoginOptFunc {
+	return func(opt *loginOption) {
+		if l != nil {
+			opt.Limiter = l
+		}
+	}
+}
+
+// LoginHandler handle POST login form, verify password, call Signin and
+// redirect to the stored return URL
+func LoginHandler(a Authorizer, lookup UserLookup, opts ...LoginOptFunc) http.Handler {
+	lo := &loginOption{
+		UserField: "username",
+		PassField: "password",
+		Redirect:  "/",
+	}
+	for _, fn := range opts {
+		fn(lo)
+	}
+	if lo.Limiter == nil {
+		lo.Limiter = NewLimiter(5, 15*time.Minute)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		uid := strings.TrimSpace(r.PostFormValue(lo.UserField))
+		password := r.PostFormValue(lo.PassField)
+		if len(uid) == 0 || len(password) == 0 {
+			http.Error(w, ErrInvalidCredentials.Error(), http.StatusBadRequest)
+			return
+		}
+
+		keyUID, keyIP := "uid:"+uid, "ip:"+ClientIP(a, r)
+		if lo.Limiter.Locked(keyUID) || lo.Limiter.Locked(keyIP) {
+			slog.Info("login locked", "uid", uid, "ip", keyIP)
+			http.Error(w, ErrLoginLocked.Error(), http.StatusTooManyRequests)
+			return
+		}
+
+		iu, hash, err := lookup.LookupUser(r.Context(), uid)
+		if err != nil || iu == nil {
+			_, _, _ = VerifyPassword(dummyHash(), password) // same cost as a real check
+			lo.Limiter.Fail(keyUID)
+			lo.Limiter.Fail(keyIP)
+			slog.Info("login lookup fail", "uid", uid, "err", err)
+			http.Error(w, ErrInvalidCredentials.Error(), http.StatusUnauthorized)
+			return
+		}
+		ok, rehash, err := VerifyPassword(hash, password)
+		if err != nil || !ok {
+			lo.Limiter.Fail(keyUID)
+			lo.Limiter.Fail(keyIP)
+			slog.Info("login password mismatch", "uid", uid, "err", err)
+			http.Error(w, ErrInvalidCredentials.Error(), http.StatusUnauthorized)
+			return
+		}
+		// the IP key is never reset, a valid account must not clear guesses at others
+		lo.Limiter.Reset(keyUID)
+
+		if pu, ok := lookup.(PasswordUpdater); ok && rehash {
+			if s, err := HashPassword(password); err == nil {
+				if err = pu.UpdatePassword(r.Context(), uid, s); err != nil {
+					slog.Info("rehash password fail", "uid", uid, "err", err)
+				}
+			}
+		}
+
+		user := ToUser(iu)
+		user.Refresh()
+		user.Authenticated(AmrPassword)
+		Bind(a, &user, r)
+		if err = a.Signin(&user, w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, popReturn(w, r, lo.Redirect), http.StatusSeeOther)
+	})
+}
+
+// LogoutHandler call Signout and redirect to uri, default: /
+func LogoutHandler(a Authorizer, uri string) http.Handler {
+	if len(uri) == 0 {
+		uri = "/"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Signout(w)
+		http.Redirect(w, r, uri, http.StatusSeeOther)
+	})
+}
+
+// storeReturn keep current URL into cookie before redirect to login
+func storeReturn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     ReturnCookieName,
+		Value:    r.URL.RequestURI(),
+		MaxAge:   600,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// popReturn get and clear the stored return URL, only local paths accepted
+func popReturn(w http.ResponseWriter, r *http.Request, dft string) string {
+	ck, err := r.Cookie(ReturnCookieName)
+	if err != nil {
+		return dft
+	}
+	http.SetCookie(w, &http.Cookie{Name: ReturnCookieName, MaxAge: -1, Path: "/", HttpOnly: true})
+	if isLocalURL(ck.Value) {
+		return ck.Value
+	}
+	return dft
+}
+
+func isLocalURL(s string) bool {
+	return strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "/\\")
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type mockLookup struct {
+	users   map[string]string // uid: hash
+	updated map[string]string
+}
+
+func (l *mockLookup) LookupUser(ctx context.Context, uid string) (IUser, string, error) {
+	if hash, ok := l.users[uid]; ok {
+		return mockUser{uid: uid, name: "Name of " + uid}, hash, nil
+	}
+	return nil, "", errors.New("not found")
+}
+
+func (l *mockLookup) UpdatePassword(ctx context.Context, uid, hash string) error {
+	l.users[uid] = hash
+	l.updated[uid] = hash
+	return nil
+}
+
+func postLogin(h http.Handler, uid, password string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	form := url.Values{"username": {uid}, "password": {password}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, ck := range cookies {
+		req.AddCookie(ck)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+
+	ok, rehash, err := VerifyPassword(hash, "secret")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, _, err = VerifyPassword(hash, "wrong")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	b, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	ok, rehash, err = VerifyPassword(string(b), "secret")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	_, _, err = VerifyPassword("plain", "secret")
+	assert.Equal(t, ErrInvalidHash, err)
+}
+
+func TestLoginHandler(t *testing.T) {
+	b, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	lookup := &mockLookup{users: map[string]string{"alice": string(b)}, updated: map[string]string{}}
+	a := New(WithURI("/login"))
+	h := LoginHandler(a, lookup)
+
+	// redirect to login stores the return URL
+	mw := a.MiddlewareWordy(true)(http.NotFoundHandler())
+	w := httptest.NewRecorder()
+	mw.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin?x=1", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	var ret *http.Cookie
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == ReturnCookieName {
+			ret = ck
+		}
+	}
+	assert.NotNil(t, ret)
+
+	w = postLogin(h, "alice", "secret", ret)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/admin?x=1", w.Header().Get("Location"))
+	assert.Contains(t, w.Header().Values("Set-Cookie")[0], "_user=")
+	assert.Contains(t, lookup.updated["alice"], "$argon2id$")
+
+	w = postLogin(h, "alice", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = postLogin(h, "nobody", "secret")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postLogin(h, "alice", "secret", &http.Cookie{Name: ReturnCookieName, Value: "//evil.com"})
+	assert.Equal(t, "/", w.Header().Get("Location"))
+
+	req := httptest.NewRequest(http.MethodGet, "/login", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestLoginLockout(t *testing.T) {
+	hash, _ := HashPassword("secret")
+	lookup := &mockLookup{users: map[string]string{"bob": hash}, updated: map[string]string{}}
+	h := LoginHandler(New(), lookup, WithLimiter(NewLimiter(2, time.Minute)))
+
+	assert.Equal(t, http.StatusUnauthorized, postLogin(h, "bob", "x").Code)
+	assert.Equal(t, http.StatusUnauthorized, postLogin(h, "bob", "y").Code)
+	assert.Equal(t, http.StatusTooManyRequests, postLogin(h, "bob", "secret").Code)
+}
+
+func TestLoginLockoutIP(t *testing.T) {
+	hash, _ := HashPassword("secret")
+	lookup := &mockLookup{users: map[string]string{"mallory": hash}, updated: map[string]string{}}
+	h := LoginHandler(New(), lookup, WithLimiter(NewLimiter(3, time.Minute)))
+
+	// a valid account of the attacker does not clear the IP counter
+	assert.Equal(t, http.StatusUnauthorized, postLogin(h, "alice", "guess1").Code)
+	assert.Equal(t, http.StatusUnauthorized, postLogin(h, "bob", "guess2").Code)
+	assert.Equal(t, http.StatusSeeOther, postLogin(h, "mallory", "secret").Code)
+	assert.Equal(t, http.StatusUnauthorized, postLogin(h, "carol", "guess3").Code)
+	assert.Equal(t, http.StatusTooManyRequests, postLogin(h, "dave", "guess4").Code)
+}
+
+func TestLoginLockoutProxy(t *testing.T) {
+	hash, _ := HashPassword("secret")
+	lookup := &mockLookup{users: map[string]string{"mallory": hash}, updated: map[string]string{}}
+	a := New(WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+	h := LoginHandler(a, lookup, WithLimiter(NewLimiter(2, time.Minute)))
+	post := func(ip, uid, password string) int {
+		form := url.Values{"username": {uid}, "password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set(HeaderForwardedFor, ip)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// failures of one client behind the proxy don't lock out others
+	assert.Equal(t, http.StatusUnauthorized, post("198.51.100.1", "alice", "guess1"))
+	assert.Equal(t, http.StatusUnauthorized, post("198.51.100.1", "bob", "guess2"))
+	assert.Equal(t, http.StatusTooManyRequests, post("198.51.100.1", "mallory", "secret"))
+	assert.Equal(t, http.StatusSeeOther, post("198.51.100.2", "mallory", "secret"))
+}
+
+func TestLimiterWindow(t *testing.T) {
+	l := NewLimiter(3, 50*time.Millisecond)
+	l.Fail("k")
+	l.Fail("k")
+	time.Sleep(60 * time.Millisecond)
+	l.Fail("k") // the first two are out of the window
+	assert.False(t, l.Locked("k"))
+	l.Fail("k")
+	l.Fail("k")
+	assert.True(t, l.Locked("k"))
+	time.Sleep(60 * time.Millisecond)
+	assert.False(t, l.Locked("k"))
+}
+
+func TestLogoutHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	LogoutHandler(New(), "").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logout", nil))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "Max-Age=0")
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vars
+var (
+	ErrInvalidOnceToken = errors.New("invalid one-time token")
+	ErrOnceTokenExpired = errors.New("one-time token is expired")
+	ErrOnceTokenUsed    = errors.New("one-time token is already used")
+)
+
+// PurposeLogin the purpose of magic-link login tokens
+const PurposeLogin = "login"
+
+// OnceStore record consumed token IDs to prevent replay
+type OnceStore interface {
+	// Consume mark id as used until exp, return false if it was used
+	Consume(ctx context.Context, id string, exp time.Time) (bool, error)
+}
+
+// UserGetter find a user by uid or email
+type UserGetter interface {
+	GetUser(ctx context.Context, uid string) (IUser, error)
+}
+
+// LinkSender deliver a login link to user, e.g. by email
+type LinkSender interface {
+	SendLink(ctx context.Context, user IUser, link string) error
+}
+
+type memOnceStore struct {
+	mu    sync.Mutex
+	items map[string]time.Time
+}
+
+// NewOnceStore return an in-memory OnceStore
+func NewOnceStore() OnceStore {
+	return &memOnceStore{items: make(map[string]time.Time)}
+}
+
+func (s *memOnceStore) Consume(ctx context.Context, id string, exp time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if len(s.items) > 1000 {
+		for k, t := range s.items {
+			if t.Before(now) {
+				delete(s.items, k)
+			}
+		}
+	}
+	if _, ok := s.items[id]; ok {
+		return false, nil
+	}
+	s.items[id] = exp
+	return true, nil
+}
+
+// OnceIssuer issue and verify short-lived, single-use, purpose-bound tokens
+type OnceIssuer struct {
+	keys  [][]byte // the first signs
+	store OnceStore
+}
+
+// NewOnceIssuer return an OnceIssuer signs tokens (HMAC-SHA256) with a key derived
+// from WithSecret of a, rotated secrets are accepted too, return ErrNoSecret without
+func NewOnceIssuer(a Authorizer, store OnceStore) (*OnceIssuer, error) {
+	tc, err := codecOf(a, true)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		store = NewOnceStore()
+	}
+	return &OnceIssuer{keys: tc.deriveKeys("simpauth once"), store: store}, nil
+}
+
+// Issue return a token of uid for purpose, valid in ttl
+func (oi *OnceIssuer) Issue(uid, purpose string, ttl time.Duration) (string, error) {
+	jti := make([]byte, 12)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	exp := time.Now().Add(ttl).Unix()
+	payload := strings.Join([]string{purpose, hex.EncodeToString(jti), strconv.FormatInt(exp, 10), uid}, "\n")
+	b64 := base64.RawURLEncoding
+	return b64.EncodeToString([]byte(payload)) + "." + b64.EncodeToString(oi.sign(payload, oi.keys[0])), nil
+}
+
+// Consume verify the token for purpose and mark it used, return the uid
+func (oi *OnceIssuer) Consume(ctx context.Context, token, purpose string) (uid string, err error) {
+	b64 := base64.RawURLEncoding
+	p, s, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidOnceToken
+	}
+	payload, err := b64.DecodeString(p)
+	if err != nil {
+		return "", ErrInvalidOnceToken
+	}
+	sig, err := b64.DecodeString(s)
+	if err != nil || !oi.verify(string(payload), sig) {
+		return "", ErrInvalidOnceToken
+	}
+	parts := strings.SplitN(string(payload), "\n", 4)
+	if len(parts) != 4 || parts[0] != purpose {
+		return "", ErrInvalidOnceToken
+	}
+	exp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", ErrInvalidOnceToken
+	}
+	if exp < time.Now().Unix() {
+		return "", ErrOnceTokenExpired
+	}
+	fresh, err := oi.store.Consume(ctx, purpose+":"+parts[1], time.Unix(exp, 0))
+	if err != nil {
+		return "", err
+	}
+	if !fresh {
+		return "", ErrOnceTokenUsed
+	}
+	return parts[3], nil
+}
+
+func (oi *OnceIssuer) sign(payload string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func (oi *OnceIssuer) verify(payload string, sig []byte) bool {
+	for _, key := range oi.keys {
+		if hmac.Equal(sig, oi.sign(payload, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MagicLink passwordless login by one-time link
+type MagicLink struct {
+	*OnceIssuer
+
+	URL      string        // absolute URL of VerifyHandler
+	TTL      time.Duration // lifetime of link, default 15 minutes
+	Redirect string        // redirect after login if no return URL stored, default /
+	Limiter  Limiter       // sent links per uid and per client IP, default: 5 in 15 minutes
+
+	a      Authorizer
+	users  UserGetter
+	sender LinkSender
+}
+
+// NewMagicLink return a MagicLink, url is the absolute URL of VerifyHandler
+func NewMagicLink(a Authorizer, oi *OnceIssuer, users UserGetter, sender LinkSender, url string) *MagicLink {
+	return &MagicLink{
+		OnceIssuer: oi,
+		URL:        url,
+		TTL:        15 * time.Minute,
+		Redirect:   "/",
+		Limiter:    NewLimiter(5, 15*time.Minute),
+		a:          a,
+		users:      users,
+		sender:     sender,
+	}
+}
+
+// Send issue a login token for uid and deliver the link
+func (ml *MagicLink) Send(ctx context.Context, uid string) error {
+	user, err := ml.users.GetUser(ctx, uid)
+	if err != nil {
+		return err
+	}
+	token, err := ml.Issue(user.GetUID(), PurposeLogin, ml.TTL)
+	if err != nil {
+		return err
+	}
+	link := ml.URL
+	if strings.Contains(link, "?") {
+		link += "&"
+	} else {
+		link += "?"
+	}
+	link += "token=" + url.QueryEscape(token)
+	return ml.sender.SendLink(ctx, user, link)
+}
+
+// SendHandler handle POST form with uid (or email), always accepted to hide unknown users,
+// requests are limited per uid and per client IP, both known or not
+func (ml *MagicLink) SendHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		uid := strings.TrimSpace(r.PostFormValue("uid"))
+		if len(uid) == 0 {
+			http.Error(w, "uid is required", http.StatusBadRequest)
+			return
+		}
+		keyUID, keyIP := "link:"+uid, "link-ip:"+ClientIP(ml.a, r)
+		if ml.Limiter.Locked(keyUID) || ml.Limiter.Locked(keyIP) {
+			slog.Info("magic link locked", "uid", uid, "ip", keyIP)
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		ml.Limiter.Fail(keyUID)
+		ml.Limiter.Fail(keyIP)
+		if err := ml.Send(r.Context(), uid); err != nil {
+			slog.Info("send magic link fail", "uid", uid, "err", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// confirmForm let the user POST the token, link scanners of mail clients only GET
+var confirmForm = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="referrer" content="no-referrer"><title>Sign in</title></head>
+<body><form method="post"><input type="hidden" name="token" value="{{.}}"><button type="submit">Sign in</button></form></body></html>
+`))
+
+// VerifyHandler exchange the token in link for a Signin cookie, GET render a form
+// to confirm and POST consume the token, so that prefetching the link does not use it
+func (ml *MagicLink) VerifyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			if err := confirmForm.Execute(w, r.FormValue("token")); err != nil {
+				slog.Info("render confirm fail", "err", err)
+			}
+			return
+		case http.MethodPost:
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		uid, err := ml.Consume(r.Context(), r.PostFormValue("token"), PurposeLogin)
+		if err != nil {
+			slog.Info("consume magic link fail", "err", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		iu, err := ml.users.GetUser(r.Context(), uid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		user := ToUser(iu)
+		user.Refresh()
+		user.Authenticated(AmrLink)
+		Bind(ml.a, &user, r)
+		if err = ml.a.Signin(&user, w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, popReturn(w, r, ml.Redirect), http.StatusSeeOther)
+	})
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockUsers map[string]mockUser
+
+func (m mockUsers) GetUser(ctx context.Context, uid string) (IUser, error) {
+	if u, ok := m[uid]; ok {
+		return u, nil
+	}
+	return nil, errors.New("not found")
+}
+
+// fakeSender keeps the last link sent
+type fakeSender struct {
+	to   string
+	link string
+}
+
+func (s *fakeSender) SendLink(ctx context.Context, user IUser, link string) error {
+	s.to, s.link = user.GetUID(), link
+	return nil
+}
+
+func TestOnceIssuer(t *testing.T) {
+	ctx := context.Background()
+	oi, err := NewOnceIssuer(New(WithSecret(testSecret)), nil)
+	assert.Nil(t, err)
+
+	token, err := oi.Issue("alice", PurposeLogin, time.Minute)
+	assert.Nil(t, err)
+
+	_, err = oi.Consume(ctx, token, "reset")
+	assert.Equal(t, ErrInvalidOnceToken, err)
+
+	uid, err := oi.Consume(ctx, token, PurposeLogin)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", uid)
+
+	_, err = oi.Consume(ctx, token, PurposeLogin)
+	assert.Equal(t, ErrOnceTokenUsed, err)
+
+	other, _ := NewOnceIssuer(New(WithSecret([]byte("other"))), nil)
+	_, err = other.Consume(ctx, token, PurposeLogin)
+	assert.Equal(t, ErrInvalidOnceToken, err)
+
+	// rotated secrets
+	token, _ = oi.Issue("alice", PurposeLogin, time.Minute)
+	rotated, _ := NewOnceIssuer(New(WithSecret([]byte("new key"), testSecret)), nil)
+	_, err = rotated.Consume(ctx, token, PurposeLogin)
+	assert.Nil(t, err)
+
+	// session tokens can't be used as one-time tokens
+	_, err = oi.Consume(ctx, signToken(t, New(WithSecret(testSecret)), &User{UID: "alice"}), PurposeLogin)
+	assert.Equal(t, ErrInvalidOnceToken, err)
+
+	_, err = NewOnceIssuer(New(), nil)
+	assert.Equal(t, ErrNoSecret, err)
+
+	token, _ = oi.Issue("alice", PurposeLogin, -time.Minute)
+	_, err = oi.Consume(ctx, token, PurposeLogin)
+	assert.Equal(t, ErrOnceTokenExpired, err)
+
+	_, err = oi.Consume(ctx, "bad-token", PurposeLogin)
+	assert.Equal(t, ErrInvalidOnceToken, err)
+}
+
+func TestMagicLink(t *testing.T) {
+	users := mockUsers{"alice": {uid: "alice", name: "Alice"}}
+	sender := new(fakeSender)
+	a := New(WithSecret(testSecret))
+	oi, _ := NewOnceIssuer(a, nil)
+	ml := NewMagicLink(a, oi, users, sender, "https://example.net/login/verify")
+	ml.Limiter = NewLimiter(2, time.Minute)
+	send := func(uid string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login/link", strings.NewReader("uid="+uid))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ml.SendHandler().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, send("nobody"))
+	assert.Equal(t, http.StatusAccepted, send("alice"))
+	assert.Equal(t, "alice", sender.to)
+	// limited per client IP, unknown users count too
+	assert.Equal(t, http.StatusTooManyRequests, send("alice"))
+
+	link, err := url.Parse(sender.link)
+	assert.Nil(t, err)
+	token := link.Query().Get("token")
+	verify := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, link.Path, strings.NewReader(url.Values{"token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ml.VerifyHandler().ServeHTTP(w, req)
+		return w
+	}
+
+	// GET only renders the confirm form
+	for range 2 {
+		w := httptest.NewRecorder()
+		ml.VerifyHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+		assert.Contains(t, w.Body.String(), `method="post"`)
+		assert.Contains(t, w.Body.String(), `value="`+token+`"`)
+	}
+
+	w := verify()
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	u, err := a.(*option).parseToken(w.Result().Cookies()[0].Value)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", u.UID)
+	assert.True(t, u.AuthMeths.Has(AmrLink))
+
+	// replay
+	assert.Equal(t, http.StatusUnauthorized, verify().Code)
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// vars
+var (
+	ErrNoClientCert = errors.New("no verified client certificate")
+)
+
+// CertMapper map a verified client certificate to a user
+type CertMapper func(cert *x509.Certificate) (*User, error)
+
+// WithCertMapper The option authenticate requests without token by the verified
+// TLS client certificate, the server must verify client certs (tls.RequireAndVerifyClientCert)
+func WithCertMapper(fn CertMapper) OptFunc {
+	return func(opt *option) {
+		opt.CertMapper = fn
+	}
+}
+
+// CertToUser the default CertMapper, UID is the SPIFFE ID (spiffe://...) if present,
+// else the subject CN, Roles are the subject OUs
+func CertToUser(cert *x509.Certificate) (*User, error) {
+	user := &User{
+		UID:   cert.Subject.CommonName,
+		Name:  cert.Subject.CommonName,
+		Roles: Names(cert.Subject.OrganizationalUnit),
+	}
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			user.UID = u.String()
+			break
+		}
+	}
+	if len(user.UID) == 0 && len(cert.DNSNames) > 0 {
+		user.UID = cert.DNSNames[0]
+	}
+	if len(user.UID) == 0 {
+		return nil, ErrNoClientCert
+	}
+	return user, nil
+}
+
+// userFromCert ...
+func (opt *option) userFromCert(r *http.Request) (*User, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoTokenInRequest
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	user, err := opt.CertMapper(cert)
+	if err != nil {
+		slog.Info("map client cert fail", "subject", cert.Subject.String(), "err", err)
+		return nil, err
+	}
+	user.Refresh()
+	user.Authenticated(AmrMTLS)
+	return user, nil
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newClientCert(t *testing.T, tpl *x509.Certificate) (tls.Certificate, *x509.CertPool) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	assert.Nil(t, err)
+	ca, _ = x509.ParseCertificate(caDER)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tpl.SerialNumber = big.NewInt(2)
+	tpl.NotBefore = time.Now().Add(-time.Hour)
+	tpl.NotAfter = time.Now().Add(time.Hour)
+	tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca, &key.PublicKey, caKey)
+	assert.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestCertToUser(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.net/ns/prod/sa/billing")
+	u, err := CertToUser(&x509.Certificate{
+		Subject: pkix.Name{CommonName: "billing", OrganizationalUnit: []string{"service"}},
+		URIs:    []*url.URL{spiffe},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "spiffe://example.net/ns/prod/sa/billing", u.UID)
+	assert.Equal(t, "billing", u.Name)
+	assert.Equal(t, Names{"service"}, u.Roles)
+
+	u, err = CertToUser(&x509.Certificate{DNSNames: []string{"worker.internal"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "worker.internal", u.UID)
+
+	_, err = CertToUser(&x509.Certificate{})
+	assert.Equal(t, ErrNoClientCert, err)
+}
+
+func TestMTLS(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.net/sa/billing")
+	cert, pool := newClientCert(t, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "billing", OrganizationalUnit: []string{"service"}},
+		URIs:    []*url.URL{spiffe},
+	})
+
+	a := New(WithCertMapper(CertToUser))
+	srv := httptest.NewUnstartedServer(a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := UserFromContext(r.Context())
+		assert.True(t, ok)
+		assert.True(t, user.AuthMeths.Has(AmrMTLS))
+		_, _ = io.WriteString(w, user.UID)
+	})))
+	srv.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: pool}
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := srv.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{cert}
+	res, err := client.Get(srv.URL)
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "spiffe://example.net/sa/billing", string(body))
+
+	// without client certificate
+	tr := client.Transport.(*http.Transport).Clone()
+	tr.TLSClientConfig.Certificates = nil
+	res, err = (&http.Client{Transport: tr}).Get(srv.URL)
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClientNotFound ...
+var ErrClientNotFound = errors.New("client not found")
+
+// Client a registered OAuth2 client
+type Client struct {
+	ID           string
+	Secret       string   // empty for public clients, which must use PKCE
+	RedirectURIs []string // exact match
+	Scopes       []string // scopes the client may request, also the default, empty for any
+	Trusted      bool     // first-party client, the user is not asked for consent
+}
+
+// ConsentFunc ask the user to approve scopes for a client, return true if approved,
+// otherwise the response is written, e.g. a consent page posting back to the
+// application, which records the approval and redirects to the authorize URL again
+type ConsentFunc func(w http.ResponseWriter, r *http.Request, client *Client, user *User, scopes Names) bool
+
+// ClientStore find registered clients
+type ClientStore interface {
+	GetClient(ctx context.Context, id string) (*Client, error)
+}
+
+// ClientMap a static ClientStore
+type ClientMap map[string]*Client
+
+// GetClient ...
+func (m ClientMap) GetClient(ctx context.Context, id string) (*Client, error) {
+	if c, ok := m[id]; ok {
+		return c, nil
+	}
+	return nil, ErrClientNotFound
+}
+
+// OAuthError an error response, see RFC 6749 section 5.2
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthErr(code, desc string) *OAuthError {
+	return &OAuthError{Code: code, Description: desc}
+}
+
+// TokenResponse see RFC 6749 section 5.1
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	scopes      Names
+	user        User
+	exp         time.Time
+}
+
+// Provider a minimal OAuth2 authorization server on top of an Authorizer,
+// the access tokens are signed User with Expiry, Client and Scopes, accepted
+// by UserFromRequest. It requires WithSecret of the Authorizer
+type Provider struct {
+	CodeTTL time.Duration // default 1 minute
+	Tokens  *TokenIssuer  // issue and rotate tokens, AccessTTL default 1 hour
+	Consent ConsentFunc   // required for clients not Trusted, they are denied without
+
+	a       Authorizer
+	clients ClientStore
+	revoker Revoker
+
+	mu    sync.Mutex
+	codes map[string]*grant
+}
+
+// NewProvider return a Provider, users are looked up again on refresh, no refresh
+// tokens are issued if it is nil. revoker should be the same one set by WithRevoker
+func NewProvider(a Authorizer, clients ClientStore, users UserGetter, revoker Revoker) *Provider {
+	ti := NewTokenIssuer(a, nil)
+	ti.AccessTTL = time.Hour
+	ti.Users = users
+	return &Provider{
+		CodeTTL: time.Minute,
+		Tokens:  ti,
+		a:       a,
+		clients: clients,
+		revoker: revoker,
+		codes:   make(map[string]*grant),
+	}
+}
+
+// AuthorizeHandler the authorization endpoint, issue a code to the signed-in user
+// after Consent of clients not Trusted, redirect to login (see WithURI) if not signed in.
+// Tokens with Scopes, Client or Expiry are denied, only sessions of the user may authorize
+func (p *Provider) AuthorizeHandler() http.Handler {
+	return p.a.MiddlewareWordy(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		client, err := p.clients.GetClient(r.Context(), q.Get("client_id"))
+		redirectURI := q.Get("redirect_uri")
+		if err != nil || !slices.Contains(client.RedirectURIs, redirectURI) {
+			// never redirect to an unverified URI
+			http.Error(w, "invalid client or redirect_uri", http.StatusBadRequest)
+			return
+		}
+		ru, _ := url.Parse(redirectURI)
+		v := ru.Query()
+		if s := q.Get("state"); s != "" {
+			v.Set("state", s)
+		}
+		challenge := q.Get("code_challenge")
+		user, _ := UserFromContext(r.Context())
+		scopes, ok := grantScopes(client, user, q.Get("scope"))
+		switch {
+		case q.Get("response_type") != "code":
+			v.Set("error", "unsupported_response_type")
+		case challenge == "" && client.Secret == "":
+			v.Set("error", "invalid_request")
+			v.Set("error_description", "PKCE is required")
+		case challenge != "" && q.Get("code_challenge_method") != "S256":
+			v.Set("error", "invalid_request")
+			v.Set("error_description", "only S256 is supported")
+		case len(user.Scopes) > 0 || len(user.Client) > 0 || user.Expiry > 0:
+			// a narrowed or issued token is not a session of the resource owner
+			v.Set("error", "access_denied")
+			v.Set("error_description", "a session is required")
+		case !ok:
+			v.Set("error", "invalid_scope")
+		case !client.Trusted && p.Consent == nil:
+			v.Set("error", "access_denied")
+			v.Set("error_description", "consent is required")
+		default:
+			if !client.Trusted && !p.Consent(w, r, client, user, scopes) {
+				return
+			}
+			code := randString(24)
+			now := time.Now()
+			p.mu.Lock()
+			for k, g := range p.codes {
+				if g.exp.Before(now) {
+					delete(p.codes, k)
+				}
+			}
+			p.codes[code] = &grant{
+				clientID:    client.ID,
+				redirectURI: redirectURI,
+				challenge:   challenge,
+				scopes:      scopes,
+				user:        *user,
+				exp:         now.Add(p.CodeTTL),
+			}
+			p.mu.Unlock()
+			v.Set("code", code)
+		}
+		ru.RawQuery = v.Encode()
+		http.Redirect(w, r, ru.String(), http.StatusFound)
+	}))
+}
+
+// TokenHandler the token endpoint, support grant types: authorization_code, refresh_token
+func (p *Provider) TokenHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		client, err := p.authClient(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			writeJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+		var res *TokenResponse
+		switch r.PostFormValue("grant_type") {
+		case "authorization_code":
+			var g *grant
+			if g, err = p.takeCode(client, r.PostFormValue("code"), r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier")); err == nil {
+				res, err = p.issue(r.Context(), g)
+			}
+		case "refresh_token":
+			res, err = p.Tokens.refresh(r.Context(), r.PostFormValue("refresh_token"), client.ID)
+			if errors.Is(err, ErrInvalidRefresh) || errors.Is(err, ErrRefreshReused) {
+				err = oauthErr("invalid_grant", err.Error())
+			}
+		default:
+			err = oauthErr("unsupported_grant_type", "")
+		}
+		var oe *OAuthError
+		if errors.As(err, &oe) {
+			slog.Info("oauth token fail", "client", client.ID, "err", err)
+			writeJSON(w, http.StatusBadRequest, err)
+			return
+		}
+		if err != nil {
+			slog.Info("oauth issue fail", "client", client.ID, "err", err)
+			writeJSON(w, http.StatusInternalServerError, oauthErr("server_error", err.Error()))
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, res)
+	})
+}
+
+// RevokeHandler the revocation endpoint, see RFC 7009
+func (p *Provider) RevokeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		client, err := p.authClient(r)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+		err = p.revoke(r.Context(), client, r.PostFormValue("token"))
+		var oe *OAuthError
+		if errors.As(err, &oe) {
+			slog.Info("oauth revoke fail", "client", client.ID, "err", err)
+			writeJSON(w, http.StatusBadRequest, err)
+			return
+		}
+		if err != nil {
+			slog.Info("oauth revoke fail", "client", client.ID, "err", err)
+			writeJSON(w, http.StatusInternalServerError, oauthErr("server_error", err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// revoke a refresh or access token only if issued to client, see RFC 7009 section 2.1,
+// invalid tokens do not cause an error response
+func (p *Provider) revoke(ctx context.Context, client *Client, token string) error {
+	errOther := oauthErr("unauthorized_client", "token was issued to another client")
+	found, err := p.Tokens.revoke(ctx, token, client.ID)
+	if found {
+		if errors.Is(err, ErrInvalidRefresh) {
+			return errOther
+		}
+		return err
+	}
+	tc, err := codecOf(p.a, true)
+	if err != nil || p.revoker == nil {
+		return nil
+	}
+	user, err := tc.parseToken(token)
+	if err != nil {
+		return nil
+	}
+	if user.Client != client.ID {
+		return errOther
+	}
+	p.revoker.Revoke(token, time.Unix(user.ExpiresAt(), 0))
+	return nil
+}
+
+func (p *Provider) authClient(r *http.Request) (*Client, error) {
+	return authClient(p.clients, r)
+}
+
+// authClient authenticate client by basic auth or form, public clients by client_id only
+func authClient(clients ClientStore, r *http.Request) (*Client, error) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	client, err := clients.GetClient(r.Context(), id)
+	if err != nil {
+		return nil, oauthErr("invalid_client", "")
+	}
+	if client.Secret != "" && subtle.ConstantTimeCompare([]byte(client.Secret), []byte(secret)) != 1 {
+		return nil, oauthErr("invalid_client", "")
+	}
+	return client, nil
+}
+
+func (p *Provider) takeCode(client *Client, code, redirectURI, verifier string) (*grant, error) {
+	p.mu.Lock()
+	g, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if !ok || g.clientID != client.ID || g.redirectURI != redirectURI || g.exp.Before(time.Now()) {
+		return nil, oauthErr("invalid_grant", "invalid code")
+	}
+	if g.challenge != "" && subtle.ConstantTimeCompare([]byte(pkceChallenge(verifier)), []byte(g.challenge)) != 1 {
+		return nil, oauthErr("invalid_grant", "invalid code_verifier")
+	}
+	return g, nil
+}
+
+// grantScopes return the requested scopes if allowed to the client and user, default the client scopes,
+// never empty, tokens without Scopes have full access
+func grantScopes(client *Client, user *User, scope string) (Names, bool) {
+	scopes := Names(strings.Fields(scope))
+	if len(scopes) == 0 {
+		scopes = append(scopes, client.Scopes...)
+	}
+	if len(scopes) == 0 {
+		return nil, false
+	}
+	for _, s := range scopes {
+		if (len(client.Scopes) > 0 && !slices.Contains(client.Scopes, s)) || !user.HasScope(s) {
+			return nil, false
+		}
+	}
+	return scopes, true
+}
+
+// issue the tokens of an authorization code, the client and scopes are recorded in the user,
+// the binding of the browser session is not for the client
+func (p *Provider) issue(ctx context.Context, g *grant) (*TokenResponse, error) {
+	user := g.user
+	user.Bound = ""
+	user.Client = g.clientID
+	user.Scopes = g.scopes
+	if p.Tokens.Users == nil {
+		return p.Tokens.access(user)
+	}
+	return p.Tokens.Issue(ctx, &user)
+}
+
+func writeJSON(w http.ResponseWriter, status int, obj any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(obj)
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postForm(h http.Handler, v url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestProvider(t *testing.T) {
+	revoker := NewRevoker()
+	a := New(WithURI("/login"), WithRevoker(revoker), WithSecret(testSecret))
+	clients := ClientMap{
+		"spa": {ID: "spa", RedirectURIs: []string{"https://app.example.net/cb"}, Scopes: []string{"read", "write"}, Trusted: true},
+		"web": {ID: "web", Secret: "s3cret", RedirectURIs: []string{"https://web.example.net/cb"}},
+	}
+	users := mockUsers{"alice": {uid: "alice", name: "Alice"}}
+	p := NewProvider(a, clients, users, revoker)
+
+	user := &User{UID: "alice", Name: "Alice", Bound: "browser"}
+	user.Refresh()
+	session := signToken(t, a, user)
+	authorize := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/authorize?"+query, nil)
+		req.AddCookie(a.Cooking(session))
+		w := httptest.NewRecorder()
+		p.AuthorizeHandler().ServeHTTP(w, req)
+		return w
+	}
+
+	verifier := randString(32)
+	q := url.Values{
+		"response_type": {"code"}, "client_id": {"spa"}, "redirect_uri": {"https://app.example.net/cb"},
+		"state": {"xyz"}, "code_challenge": {pkceChallenge(verifier)}, "code_challenge_method": {"S256"},
+		"scope": {"read"},
+	}
+
+	// not signed in
+	w := httptest.NewRecorder()
+	p.AuthorizeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil))
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+
+	// unregistered redirect_uri
+	bad := url.Values{"response_type": {"code"}, "client_id": {"spa"}, "redirect_uri": {"https://evil.com/cb"}}
+	assert.Equal(t, http.StatusBadRequest, authorize(bad.Encode()).Code)
+
+	// public client without PKCE
+	loc, _ := url.Parse(authorize("response_type=code&client_id=spa&redirect_uri=https://app.example.net/cb").Header().Get("Location"))
+	assert.Equal(t, "invalid_request", loc.Query().Get("error"))
+
+	// scope not allowed to the client
+	q.Set("scope", "read admin")
+	loc, _ = url.Parse(authorize(q.Encode()).Header().Get("Location"))
+	assert.Equal(t, "invalid_scope", loc.Query().Get("error"))
+	q.Set("scope", "read")
+
+	// no scope requested and no default of the client
+	web := url.Values{"response_type": {"code"}, "client_id": {"web"}, "redirect_uri": {"https://web.example.net/cb"}}
+	loc, _ = url.Parse(authorize(web.Encode()).Header().Get("Location"))
+	assert.Equal(t, "invalid_scope", loc.Query().Get("error"))
+
+	// third-party clients need consent
+	web.Set("scope", "read")
+	loc, _ = url.Parse(authorize(web.Encode()).Header().Get("Location"))
+	assert.Equal(t, "access_denied", loc.Query().Get("error"))
+	var asked Names
+	p.Consent = func(w http.ResponseWriter, r *http.Request, client *Client, user *User, scopes Names) bool {
+		asked = scopes
+		if r.URL.Query().Get("approved") == "" {
+			http.Redirect(w, r, "/consent", http.StatusFound)
+			return false
+		}
+		return true
+	}
+	assert.Equal(t, "/consent", authorize(web.Encode()).Header().Get("Location"))
+	assert.Equal(t, Names{"read"}, asked)
+	web.Set("approved", "1")
+	loc, _ = url.Parse(authorize(web.Encode()).Header().Get("Location"))
+	assert.NotEmpty(t, loc.Query().Get("code"))
+
+	w = authorize(q.Encode())
+	assert.Equal(t, http.StatusFound, w.Code)
+	loc, _ = url.Parse(w.Header().Get("Location"))
+	assert.Equal(t, "xyz", loc.Query().Get("state"))
+	code := loc.Query().Get("code")
+	assert.NotEmpty(t, code)
+
+	tv := url.Values{"grant_type": {"authorization_code"}, "client_id": {"spa"}, "code": {code},
+		"redirect_uri": {"https://app.example.net/cb"}, "code_verifier": {"wrong"}}
+	assert.Equal(t, http.StatusBadRequest, postForm(p.TokenHandler(), tv).Code)
+
+	// a code is single use even after a failed attempt
+	loc, _ = url.Parse(authorize(q.Encode()).Header().Get("Location"))
+	tv.Set("code", loc.Query().Get("code"))
+	tv.Set("code_verifier", verifier)
+	w = postForm(p.TokenHandler(), tv)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res TokenResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "Bearer", res.TokenType)
+	assert.Equal(t, "read", res.Scope)
+	assert.Equal(t, int64(3600), res.ExpiresIn)
+	assert.Equal(t, http.StatusBadRequest, postForm(p.TokenHandler(), tv).Code)
+
+	// access token is a signed User of the client, scopes and expiry
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("Authorization", "Bearer "+res.AccessToken)
+	got, err := a.UserFromRequest(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", got.UID)
+	assert.Equal(t, "spa", got.Client)
+	assert.Equal(t, Names{"read"}, got.Scopes)
+	assert.Empty(t, got.Bound)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), got.Expiry, 2)
+
+	// neither an access token nor a narrowed one is a session to authorize with
+	for _, tok := range []string{res.AccessToken, signToken(t, a, &User{UID: "alice", LastHit: user.LastHit, Scopes: Names{"read"}})} {
+		ar := httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil)
+		ar.Header.Set("Authorization", "Bearer "+tok)
+		w = httptest.NewRecorder()
+		p.AuthorizeHandler().ServeHTTP(w, ar)
+		loc, _ = url.Parse(w.Header().Get("Location"))
+		assert.Equal(t, "access_denied", loc.Query().Get("error"))
+		assert.Empty(t, loc.Query().Get("code"))
+	}
+
+	// refresh rotation, the user is looked up again
+	users["alice"] = mockUser{uid: "alice", name: "Alice L."}
+	rv := url.Values{"grant_type": {"refresh_token"}, "client_id": {"spa"}, "refresh_token": {res.RefreshToken}}
+	w = postForm(p.TokenHandler(), rv)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, postForm(p.TokenHandler(), rv).Code)
+	var res2 TokenResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res2))
+	got, err = a.(*option).parseToken(res2.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice L.", got.Name)
+	assert.Equal(t, Names{"read"}, got.Scopes)
+
+	// removed users can't refresh
+	delete(users, "alice")
+	rv.Set("refresh_token", res2.RefreshToken)
+	assert.Equal(t, http.StatusBadRequest, postForm(p.TokenHandler(), rv).Code)
+
+	// only the client the token is issued to can revoke it
+	w = postForm(p.RevokeHandler(), url.Values{"client_id": {"web"}, "client_secret": {"s3cret"}, "token": {res.AccessToken}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	_, err = a.UserFromRequest(req)
+	assert.Nil(t, err)
+	w = postForm(p.RevokeHandler(), url.Values{"client_id": {"spa"}, "token": {res.AccessToken}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = a.UserFromRequest(req)
+	assert.Equal(t, ErrTokenRevoked, err)
+
+	// first-party tokens can't be revoked by clients
+	w = postForm(p.RevokeHandler(), url.Values{"client_id": {"spa"}, "token": {session}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// confidential client
+	w = postForm(p.TokenHandler(), url.Values{"grant_type": {"refresh_token"}, "client_id": {"web"}, "client_secret": {"x"}})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vars
+var (
+	ErrInvalidState = errors.New("invalid oidc state")
+	ErrInvalidToken = errors.New("invalid id token")
+
+	// OIDCCookieName the cookie keeps state, nonce and PKCE verifier during login
+	OIDCCookieName = "_oidc"
+)
+
+// OIDCConfig ...
+type OIDCConfig struct {
+	Issuer       string   // issuer URL, discovery from {Issuer}/.well-known/openid-configuration
+	ClientID     string   //
+	ClientSecret string   // empty for public clients
+	RedirectURL  string   // absolute URL of CallbackHandler
+	Scopes       []string // default: openid profile email
+
+	// claim paths, nested with dot, e.g. realm_access.roles
+	UIDClaim    string // default: sub, the stable identifier, fallback to sub
+	NameClaim   string // default: name
+	AvatarClaim string // default: picture
+	RolesClaim  string // e.g. groups, realm_access.roles
+
+	Redirect string // redirect after login if no return URL stored, default /
+}
+
+type oidcMeta struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+}
+
+// OIDC a relying party of OpenID Connect with authorization code and PKCE
+type OIDC struct {
+	cfg    OIDCConfig
+	a      Authorizer
+	client *http.Client
+	meta   oidcMeta
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+}
+
+// NewOIDC load the discovery document of issuer and return an OIDC
+func NewOIDC(ctx context.Context, a Authorizer, cfg OIDCConfig, client *http.Client) (*OIDC, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if len(cfg.UIDClaim) == 0 {
+		cfg.UIDClaim = "sub"
+	}
+	if len(cfg.NameClaim) == 0 {
+		cfg.NameClaim = "name"
+	}
+	if len(cfg.AvatarClaim) == 0 {
+		cfg.AvatarClaim = "picture"
+	}
+	if len(cfg.Redirect) == 0 {
+		cfg.Redirect = "/"
+	}
+	o := &OIDC{cfg: cfg, a: a, client: client}
+	uri := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := o.getJSON(ctx, uri, &o.meta); err != nil {
+		return nil, err
+	}
+	if o.meta.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: %q != %q", o.meta.Issuer, cfg.Issuer)
+	}
+	return o, nil
+}
+
+// oidcSkew allowed clock skew of auth_time
+const oidcSkew = 60
+
+// LoginHandler redirect to the authorization endpoint of IdP
+func (o *OIDC) LoginHandler() http.Handler {
+	return o.authorize(-1)
+}
+
+// StepUpHandler redirect to the IdP for a new authentication (prompt=login, max_age),
+// e.g. at WithStepURI, the callback rejects ID tokens of an older authentication
+func (o *OIDC) StepUpHandler(maxAge time.Duration) http.Handler {
+	return o.authorize(max(maxAge, 0))
+}
+
+// authorize redirect to the authorization endpoint, request a new authentication within maxAge if >= 0
+func (o *OIDC) authorize(maxAge time.Duration) http.Handler {
+	age := "-1"
+	if maxAge >= 0 {
+		age = strconv.FormatInt(int64(maxAge/time.Second), 10)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, nonce, verifier := randString(16), randString(16), randString(32)
+		http.SetCookie(w, &http.Cookie{
+			Name:     OIDCCookieName,
+			Value:    state + "." + nonce + "." + verifier + "." + age,
+			MaxAge:   600,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		v := url.Values{}
+		v.Set("response_type", "code")
+		v.Set("client_id", o.cfg.ClientID)
+		v.Set("redirect_uri", o.cfg.RedirectURL)
+		v.Set("scope", strings.Join(o.cfg.Scopes, " "))
+		v.Set("state", state)
+		v.Set("nonce", nonce)
+		v.Set("code_challenge", pkceChallenge(verifier))
+		v.Set("code_challenge_method", "S256")
+		if maxAge >= 0 {
+			v.Set("max_age", age)
+			v.Set("prompt", "login")
+		}
+		sep := "?"
+		if strings.Contains(o.meta.AuthURL, "?") {
+			sep = "&"
+		}
+		http.Redirect(w, r, o.meta.AuthURL+sep+v.Encode(), http.StatusFound)
+	})
+}
+
+// CallbackHandler exchange the code, validate the ID token, map claims to User and call Signin
+func (o *OIDC) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ck, err := r.Cookie(OIDCCookieName)
+		http.SetCookie(w, &http.Cookie{Name: OIDCCookieName, MaxAge: -1, Path: "/", HttpOnly: true})
+		if err != nil {
+			http.Error(w, ErrInvalidState.Error(), http.StatusBadRequest)
+			return
+		}
+		parts := strings.Split(ck.Value, ".")
+		q := r.URL.Query()
+		if len(parts) != 4 || q.Get("state") != parts[0] {
+			http.Error(w, ErrInvalidState.Error(), http.StatusBadRequest)
+			return
+		}
+		maxAge, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil {
+			http.Error(w, ErrInvalidState.Error(), http.StatusBadRequest)
+			return
+		}
+		if s := q.Get("error"); s != "" {
+			slog.Info("oidc auth fail", "error", s, "desc", q.Get("error_description"))
+			http.Error(w, s, http.StatusUnauthorized)
+			return
+		}
+		idToken, err := o.exchange(r.Context(), q.Get("code"), parts[2])
+		if err != nil {
+			slog.Info("oidc exchange fail", "err", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		t, err := o.verify(r.Context(), idToken, parts[1])
+		if err != nil {
+			slog.Info("oidc verify fail", "err", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		authTime := t.claimInt("auth_time")
+		if maxAge >= 0 && authTime+maxAge+oidcSkew < time.Now().Unix() {
+			slog.Info("oidc auth is too old", "auth_time", authTime, "max_age", maxAge)
+			http.Error(w, ErrInvalidToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		user := o.ToUser(t.Claims)
+		if len(user.UID) == 0 {
+			http.Error(w, ErrInvalidToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		user.Refresh()
+		user.Authenticated(amrOf(t.Claims)...)
+		// the IdP authenticated at auth_time, maybe an old SSO session, never fresh without
+		user.AuthTime = authTime
+		Bind(o.a, user, r)
+		if err = o.a.Signin(user, w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, popReturn(w, r, o.cfg.Redirect), http.StatusSeeOther)
+	})
+}
+
+// ToUser map claims to User by configured claim paths
+func (o *OIDC) ToUser(claims map[string]any) *User {
+	user := &User{
+		UID:    claimStr(claims, o.cfg.UIDClaim),
+		Name:   claimStr(claims, o.cfg.NameClaim),
+		Avatar: claimStr(claims, o.cfg.AvatarClaim),
+	}
+	if len(user.UID) == 0 {
+		user.UID = claimStr(claims, "sub")
+	}
+	if len(o.cfg.RolesClaim) > 0 {
+		user.Roles = claimNames(claims, o.cfg.RolesClaim)
+	}
+	return user
+}
+
+func (o *OIDC) exchange(ctx context.Context, code, verifier string) (string, error) {
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", o.cfg.RedirectURL)
+	v.Set("code_verifier", verifier)
+	v.Set("client_id", o.cfg.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.meta.TokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(o.cfg.ClientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+	}
+	res, err := o.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK || len(body.IDToken) == 0 {
+		return "", fmt.Errorf("token endpoint: %d %s", res.StatusCode, body.Error)
+	}
+	return body.IDToken, nil
+}
+
+func (o *OIDC) verify(ctx context.Context, s, nonce string) (*jwtToken, error) {
+	t, err := parseJWT(s)
+	if err != nil {
+		return nil, err
+	}
+	key, err := o.key(ctx, t.Header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.verify(key); err != nil {
+		return nil, err
+	}
+	if t.claimString("iss") != o.meta.Issuer || !claimNames(t.Claims, "aud").Has(o.cfg.ClientID) {
+		return nil, ErrInvalidToken
+	}
+	if t.claimInt("exp") < time.Now().Unix() || t.claimString("nonce") != nonce {
+		return nil, ErrInvalidToken
+	}
+	return t, nil
+}
+
+// key return the public key by kid, reload JWKS once for unknown kid (key rotation)
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.RLock()
+	k, ok := o.keys[kid]
+	o.mu.RUnlock()
+	if ok {
+		return k, nil
+	}
+	var set JWKSet
+	if err := o.getJSON(ctx, o.meta.JWKSURL, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jk := range set.Keys {
+		if jk.Use != "" && jk.Use != "sig" {
+			continue
+		}
+		if pk, err := jk.PublicKey(); err == nil {
+			keys[jk.Kid] = pk
+		}
+	}
+	o.mu.Lock()
+	o.keys = keys
+	o.mu.Unlock()
+	if k, ok = keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (o *OIDC) getJSON(ctx context.Context, uri string, obj any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	res, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s: %s", uri, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(obj)
+}
+
+// claimValue get a value by nested path, e.g. realm_access.roles
+func claimValue(claims map[string]any, path string) any {
+	var v any = claims
+	for k := range strings.SplitSeq(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+func claimStr(claims map[string]any, path string) string {
+	s, _ := claimValue(claims, path).(string)
+	return s
+}
+
+// claimNames accept a string array or a space separated string
+func claimNames(claims map[string]any, path string) Names {
+	switch v := claimValue(claims, path).(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		var out Names
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func amrOf(claims map[string]any) []string {
+	amr := claimNames(claims, "amr")
+	if !slices.Contains(amr, AmrFed) {
+		amr = append(amr, AmrFed)
+	}
+	return amr
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randString(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
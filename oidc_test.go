@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIdP an in-process OpenID provider
+type fakeIdP struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	nonce     string
+	challenge string
+	query     url.Values
+	claims    map[string]any
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	idp := &fakeIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding
+		_ = json.NewEncoder(w).Encode(JWKSet{Keys: []JWK{{
+			Kty: "RSA", Kid: "k1", Use: "sig",
+			N: b64.EncodeToString(key.N.Bytes()),
+			E: b64.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		idp.nonce, idp.challenge, idp.query = q.Get("nonce"), q.Get("code_challenge"), q
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=c1&state="+q.Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "c1" || pkceChallenge(r.PostFormValue("code_verifier")) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		claims := map[string]any{
+			"iss": idp.URL, "aud": "app", "sub": "s-1", "nonce": idp.nonce,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, claims)})
+	})
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+func (idp *fakeIdP) sign(t *testing.T, claims map[string]any) string {
+	b64 := base64.RawURLEncoding
+	hb, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	pb, _ := json.Marshal(claims)
+	input := b64.EncodeToString(hb) + "." + b64.EncodeToString(pb)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func TestOIDC(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.Close()
+	idp.claims = map[string]any{
+		"preferred_username": "alice",
+		"name":               "Alice",
+		"realm_access":       map[string]any{"roles": []string{"admin", "dev"}},
+		"amr":                []string{"mfa"},
+	}
+
+	a := New(WithSecret(testSecret))
+	o, err := NewOIDC(context.Background(), a, OIDCConfig{
+		Issuer:      idp.URL,
+		ClientID:    "app",
+		RedirectURL: "http://localhost/callback",
+		RolesClaim:  "realm_access.roles",
+	}, idp.Client())
+	assert.Nil(t, err)
+
+	var cb *url.URL
+	login := func(h http.Handler) (*httptest.ResponseRecorder, *http.Cookie) {
+		// login: redirect to IdP with PKCE
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		loc, _ := url.Parse(w.Header().Get("Location"))
+		assert.Equal(t, "S256", loc.Query().Get("code_challenge_method"))
+		state := w.Result().Cookies()[0]
+
+		// IdP: redirect back with code
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Get(loc.String())
+		assert.Nil(t, err)
+		resp.Body.Close()
+		cb, _ = url.Parse(resp.Header.Get("Location"))
+
+		// callback
+		req := httptest.NewRequest(http.MethodGet, cb.RequestURI(), nil)
+		req.AddCookie(state)
+		w = httptest.NewRecorder()
+		o.CallbackHandler().ServeHTTP(w, req)
+		return w, state
+	}
+	userOf := func(w *httptest.ResponseRecorder) (user *User) {
+		for _, ck := range w.Result().Cookies() {
+			if ck.Name == "_user" {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(ck)
+				user, err = a.UserFromRequest(req)
+				assert.Nil(t, err)
+			}
+		}
+		return
+	}
+
+	w, state := login(o.LoginHandler())
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Empty(t, idp.query.Get("prompt"))
+	user := userOf(w)
+	assert.NotNil(t, user)
+	assert.Equal(t, "s-1", user.UID)
+	assert.Equal(t, "Alice", user.Name)
+	assert.Equal(t, Names{"admin", "dev"}, user.Roles)
+	assert.True(t, user.AuthMeths.Has(AmrFed))
+	// without auth_time of the IdP session, the login is not fresh
+	assert.False(t, user.IsFreshAuth(time.Hour, AmrMFA))
+
+	// bad state
+	req := httptest.NewRequest(http.MethodGet, strings.Replace(cb.RequestURI(), "state=", "state=x", 1), nil)
+	req.AddCookie(state)
+	w = httptest.NewRecorder()
+	o.CallbackHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// step-up: an old SSO session is rejected
+	idp.claims["auth_time"] = time.Now().Add(-time.Hour).Unix()
+	w, _ = login(o.StepUpHandler(5 * time.Minute))
+	assert.Equal(t, "login", idp.query.Get("prompt"))
+	assert.Equal(t, "300", idp.query.Get("max_age"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	idp.claims["auth_time"] = time.Now().Unix()
+	w, _ = login(o.StepUpHandler(5 * time.Minute))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	user = userOf(w)
+	assert.True(t, user.IsFreshAuth(5*time.Minute, AmrMFA))
+}
+
+func TestOIDCVerify(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.Close()
+	o, err := NewOIDC(context.Background(), New(), OIDCConfig{Issuer: idp.URL, ClientID: "app"}, idp.Client())
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	claims := map[string]any{"iss": idp.URL, "aud": []string{"app"}, "sub": "s-1", "nonce": "n1",
+		"exp": time.Now().Add(time.Minute).Unix()}
+	_, err = o.verify(ctx, idp.sign(t, claims), "n1")
+	assert.Nil(t, err)
+	_, err = o.verify(ctx, idp.sign(t, claims), "n2")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	claims["aud"] = "other"
+	_, err = o.verify(ctx, idp.sign(t, claims), "n1")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	claims["aud"], claims["exp"] = "app", time.Now().Add(-time.Minute).Unix()
+	_, err = o.verify(ctx, idp.sign(t, claims), "n1")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	s := idp.sign(t, claims)
+	_, err = o.verify(ctx, s[:len(s)-4]+"AAAA", "n1")
+	assert.Equal(t, ErrInvalidJWT, err)
+}
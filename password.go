@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// vars
+var (
+	ErrInvalidHash = errors.New("invalid password hash")
+
+	// Argon2Params used by HashPassword, hashes with other params need rehash
+	Argon2Params = Argon2Param{Memory: 64 * 1024, Time: 1, Threads: 4, SaltLen: 16, KeyLen: 32}
+)
+
+// Argon2Param parameters of argon2id
+type Argon2Param struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// HashPassword hash a password with argon2id, result in PHC string format
+func HashPassword(password string) (string, error) {
+	p := Argon2Params
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	b64 := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// VerifyPassword check password with a bcrypt or argon2id hash,
+// rehash is true when the hash should be replaced by HashPassword
+func VerifyPassword(hash, password string) (ok, rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		var p Argon2Param
+		var salt, key []byte
+		p, salt, key, err = parseArgon2(hash)
+		if err != nil {
+			return
+		}
+		other := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+		ok = subtle.ConstantTimeCompare(key, other) == 1
+		cur := Argon2Params
+		rehash = ok && (p.Memory != cur.Memory || p.Time != cur.Time || p.Threads != cur.Threads ||
+			p.SaltLen != cur.SaltLen || p.KeyLen != cur.KeyLen)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			err = nil
+			return
+		}
+		ok = err == nil
+		rehash = ok
+	default:
+		err = ErrInvalidHash
+	}
+	return
+}
+
+func parseArgon2(hash string) (p Argon2Param, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		err = ErrInvalidHash
+		return
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		err = ErrInvalidHash
+		return
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		err = ErrInvalidHash
+		return
+	}
+	b64 := base64.RawStdEncoding
+	if salt, err = b64.DecodeString(parts[4]); err != nil {
+		err = ErrInvalidHash
+		return
+	}
+	if key, err = b64.DecodeString(parts[5]); err != nil {
+		err = ErrInvalidHash
+		return
+	}
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+	return
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RefreshPolicy decide if a token should be re-issued
+type RefreshPolicy interface {
+	NeedRefresh(u *User, now time.Time) bool
+}
+
+// RefreshPolicyFunc ...
+type RefreshPolicyFunc func(u *User, now time.Time) bool
+
+// NeedRefresh ...
+func (f RefreshPolicyFunc) NeedRefresh(u *User, now time.Time) bool {
+	return f(u, now)
+}
+
+// FixedWindow refresh in the last Window before Lifetime ends
+func FixedWindow(lifetime, window time.Duration) RefreshPolicy {
+	return RefreshPolicyFunc(func(u *User, now time.Time) bool {
+		age := now.Sub(time.Unix(u.LastHit, 0))
+		return age < lifetime && age > lifetime-window
+	})
+}
+
+// PercentWindow refresh after percent (0-100) of lifetime passed,
+// PercentWindow(lifetime, 50) is the default behavior of User.NeedRefreshWith
+func PercentWindow(lifetime time.Duration, percent int) RefreshPolicy {
+	return RefreshPolicyFunc(func(u *User, now time.Time) bool {
+		age := now.Sub(time.Unix(u.LastHit, 0))
+		return age < lifetime && age > lifetime*time.Duration(percent)/100
+	})
+}
+
+type minInterval struct {
+	policy   RefreshPolicy
+	interval time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// MinInterval wrap a policy, re-issue at most once per interval for one user,
+// so parallel requests with the same old token do not each re-issue
+func MinInterval(p RefreshPolicy, interval time.Duration) RefreshPolicy {
+	return &minInterval{policy: p, interval: interval, last: make(map[string]time.Time)}
+}
+
+func (m *minInterval) NeedRefresh(u *User, now time.Time) bool {
+	if !m.policy.NeedRefresh(u, now) {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.last) > 1000 {
+		for k, t := range m.last {
+			if now.Sub(t) > m.interval {
+				delete(m.last, k)
+			}
+		}
+	}
+	key := u.OID + ":" + u.UID
+	if t, ok := m.last[key]; ok && now.Sub(t) < m.interval {
+		return false
+	}
+	m.last[key] = now
+	return true
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshPolicies(t *testing.T) {
+	now := time.Now()
+	at := func(ago time.Duration) *User {
+		return &User{UID: "u", LastHit: now.Add(-ago).Unix()}
+	}
+
+	p := FixedWindow(time.Hour, 10*time.Minute)
+	assert.False(t, p.NeedRefresh(at(30*time.Minute), now))
+	assert.True(t, p.NeedRefresh(at(55*time.Minute), now))
+	assert.False(t, p.NeedRefresh(at(2*time.Hour), now))
+
+	p = PercentWindow(time.Hour, 80)
+	assert.False(t, p.NeedRefresh(at(40*time.Minute), now))
+	assert.True(t, p.NeedRefresh(at(50*time.Minute), now))
+
+	p = MinInterval(PercentWindow(time.Hour, 50), time.Minute)
+	assert.True(t, p.NeedRefresh(at(40*time.Minute), now))
+	assert.False(t, p.NeedRefresh(at(40*time.Minute), now.Add(time.Second)))
+	assert.True(t, p.NeedRefresh(at(40*time.Minute), now.Add(2*time.Minute)))
+	assert.True(t, p.NeedRefresh(&User{UID: "other", LastHit: at(40 * time.Minute).LastHit}, now))
+}
+
+func TestMiddlewareRefreshPolicy(t *testing.T) {
+	user := &User{UID: "testUID"}
+	user.LastHit = time.Now().Unix() - 2400
+	token, _ := user.Encode()
+
+	opt := New(WithRefreshPolicy(MinInterval(PercentWindow(time.Hour, 50), time.Minute)))
+	var hits []int64
+	var mu sync.Mutex
+	h := opt.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := UserFromContext(r.Context())
+		mu.Lock()
+		hits = append(hits, u.LastHit)
+		mu.Unlock()
+		_, _ = w.Write([]byte("streaming"))
+		w.(http.Flusher).Flush()
+	}))
+
+	var wg sync.WaitGroup
+	var reissued sync.Map
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(opt.Cooking(token))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if len(w.Result().Cookies()) > 0 {
+				reissued.Store(i, true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	reissued.Range(func(_, _ any) bool { n++; return true })
+	assert.Equal(t, 1, n)
+	// the decoded user in context is not mutated
+	for _, hit := range hits {
+		assert.Equal(t, user.LastHit, hit)
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// headers set by an authenticating reverse proxy, e.g. oauth2-proxy, Envoy
+const (
+	HeaderForwardedUser   = "X-Forwarded-User"
+	HeaderForwardedEmail  = "X-Forwarded-Email"
+	HeaderForwardedGroups = "X-Forwarded-Groups"
+	HeaderForwardedFor    = "X-Forwarded-For"
+)
+
+// WithTrustedProxies The option trust the user in X-Forwarded-* headers from proxies,
+// the headers of other clients are stripped. The client IP of requests from proxies
+// is taken from X-Forwarded-For, see ClientIP
+func WithTrustedProxies(prefixes ...netip.Prefix) OptFunc {
+	return func(opt *option) {
+		opt.Proxies = prefixes
+	}
+}
+
+// isTrustedProxy ...
+func (opt *option) isTrustedProxy(r *http.Request) bool {
+	return opt.trusted(clientIP(r))
+}
+
+// trusted checks if ip is in Proxies
+func (opt *option) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range opt.Proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipResolver resolve the client IP of requests, implemented by authorizers of this package
+type ipResolver interface {
+	clientIP(r *http.Request) string
+}
+
+// ClientIP return the IP of client, e.g. for rate limits, the rightmost address of
+// X-Forwarded-For which is not a trusted proxy (WithTrustedProxies) of a, or the peer
+func ClientIP(a Authorizer, r *http.Request) string {
+	if ir, ok := a.(ipResolver); ok {
+		return ir.clientIP(r)
+	}
+	return clientIP(r)
+}
+
+func (opt *option) clientIP(r *http.Request) string {
+	ip := clientIP(r)
+	if !opt.trusted(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values(HeaderForwardedFor), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		ip = hop
+		if !opt.trusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// userFromProxy return the user from headers of a trusted proxy, or nil
+func (opt *option) userFromProxy(r *http.Request) *User {
+	if !opt.isTrustedProxy(r) {
+		if len(r.Header.Get(HeaderForwardedUser)) > 0 {
+			slog.Info("strip forwarded user of untrusted client", "ip", clientIP(r))
+		}
+		r.Header.Del(HeaderForwardedUser)
+		r.Header.Del(HeaderForwardedEmail)
+		r.Header.Del(HeaderForwardedGroups)
+		return nil
+	}
+	uid := r.Header.Get(HeaderForwardedUser)
+	if len(uid) == 0 {
+		return nil
+	}
+	user := &User{UID: uid, Name: uid}
+	if email := r.Header.Get(HeaderForwardedEmail); len(email) > 0 {
+		user.SetAttr("email", email)
+	}
+	for _, g := range strings.Split(r.Header.Get(HeaderForwardedGroups), ",") {
+		if g = strings.TrimSpace(g); len(g) > 0 {
+			user.Roles = append(user.Roles, g)
+		}
+	}
+	user.Refresh()
+	user.Authenticated(AmrFed)
+	return user
+}
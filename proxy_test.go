@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	a := New(WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")))
+	h := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := UserFromContext(r.Context())
+		_, _ = io.WriteString(w, user.UID+" "+r.Header.Get(HeaderForwardedUser))
+	}))
+
+	do := func(remote string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		req.Header.Set(HeaderForwardedUser, "alice")
+		req.Header.Set(HeaderForwardedEmail, "alice@example.net")
+		req.Header.Set(HeaderForwardedGroups, "admin, dev")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("10.1.2.3:5000", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice alice", w.Body.String())
+	w = do("[::1]:5000", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// untrusted client
+	assert.Equal(t, http.StatusUnauthorized, do("203.0.113.9:5000", "").Code)
+	bob := &User{UID: "bob"}
+	bob.Refresh()
+	token, _ := bob.Encode()
+	w = do("203.0.113.9:5000", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bob ", w.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:80"
+	req.Header.Set(HeaderForwardedUser, "alice")
+	req.Header.Set(HeaderForwardedEmail, "alice@example.net")
+	req.Header.Set(HeaderForwardedGroups, "admin, dev")
+	user, err := a.UserFromRequest(req)
+	assert.Nil(t, err)
+	assert.Equal(t, Names{"admin", "dev"}, user.Roles)
+	assert.Equal(t, "alice@example.net", user.Extra.GetString("email"))
+}
+
+func TestClientIP(t *testing.T) {
+	a := New(WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	req.Header.Set(HeaderForwardedFor, "198.51.100.1")
+	assert.Equal(t, "203.0.113.9", ClientIP(a, req))
+	assert.Equal(t, "203.0.113.9", ClientIP(New(), req))
+
+	// spoofed hops on the left are ignored
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set(HeaderForwardedFor, "1.2.3.4, 198.51.100.1, 10.0.0.2")
+	assert.Equal(t, "198.51.100.1", ClientIP(a, req))
+	assert.Equal(t, "198.51.100.1", ClientIP(Chain(a, New()), req))
+	req.Header.Set(HeaderForwardedFor, "bad, 10.0.0.2")
+	assert.Equal(t, "10.0.0.2", ClientIP(a, req))
+	req.Header.Del(HeaderForwardedFor)
+	assert.Equal(t, "10.0.0.1", ClientIP(a, req))
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"net/http"
+)
+
+// Recorder a http.ResponseWriter keeps the response in memory, for adapters of
+// frameworks which are not net/http, see RecordMiddleware
+type Recorder struct {
+	Code int
+	Body []byte
+
+	header http.Header
+}
+
+// NewRecorder ...
+func NewRecorder() *Recorder {
+	return &Recorder{Code: http.StatusOK, header: make(http.Header)}
+}
+
+// Header ...
+func (w *Recorder) Header() http.Header { return w.header }
+
+// WriteHeader ...
+func (w *Recorder) WriteHeader(code int) { w.Code = code }
+
+// Write ...
+func (w *Recorder) Write(b []byte) (int, error) {
+	w.Body = append(w.Body, b...)
+	return len(b), nil
+}
+
+// RecordMiddleware run mw (e.g. MiddlewareWordy of an Authorizer) for r, so that all
+// options work the same as net/http. Return the recorded response, with headers like
+// refreshed tokens, and the request passed to next with the user in context, which
+// is nil if mw responded itself, e.g. unauthorized or redirect
+func RecordMiddleware(mw func(next http.Handler) http.Handler, r *http.Request) (*Recorder, *http.Request) {
+	var next *http.Request
+	w := NewRecorder()
+	mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		next = r
+	})).ServeHTTP(w, r)
+	return w, next
+}
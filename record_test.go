@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordMiddleware(t *testing.T) {
+	a := New(WithURI("/login"), WithRefreshHeader("", RefreshHeader), WithSecret(testSecret))
+
+	w, next := RecordMiddleware(a.MiddlewareWordy(false), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, next)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, ErrNoTokenInRequest.Error(), strings.TrimSpace(string(w.Body)))
+
+	w, next = RecordMiddleware(a.MiddlewareWordy(true), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, next)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+
+	old := &User{UID: "bob", LastHit: time.Now().Add(-50 * time.Minute).Unix()}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, a, old))
+	w, next = RecordMiddleware(a.MiddlewareWordy(false), req)
+	if assert.NotNil(t, next) {
+		user, ok := UserFromContext(next.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "bob", user.UID)
+	}
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("X-Refreshed-Token"))
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vars
+var (
+	ErrInvalidRefresh = errors.New("invalid refresh token")
+	ErrRefreshReused  = errors.New("refresh token reused, family revoked")
+)
+
+// RefreshToken a stored refresh token, ID is the hash of the token string
+type RefreshToken struct {
+	ID      string
+	Family  string // all tokens rotated from one login
+	User    User
+	Expires time.Time
+	Used    bool
+	Revoked bool
+}
+
+// RefreshStore keep refresh tokens and their families
+type RefreshStore interface {
+	Save(ctx context.Context, rt *RefreshToken) error
+	// Use mark the token used, return it with the state before, nil if not found
+	Use(ctx context.Context, id string) (*RefreshToken, error)
+	RevokeFamily(ctx context.Context, family string) error
+}
+
+type memRefreshStore struct {
+	mu    sync.Mutex
+	items map[string]*RefreshToken
+}
+
+// NewRefreshStore return an in-memory RefreshStore
+func NewRefreshStore() RefreshStore {
+	return &memRefreshStore{items: make(map[string]*RefreshToken)}
+}
+
+func (s *memRefreshStore) Save(ctx context.Context, rt *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if len(s.items) > 1000 {
+		for k, v := range s.items {
+			if v.Expires.Before(now) {
+				delete(s.items, k)
+			}
+		}
+	}
+	cp := *rt
+	s.items[rt.ID] = &cp
+	return nil
+}
+
+func (s *memRefreshStore) Use(ctx context.Context, id string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rt, ok := s.items[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *rt
+	rt.Used = true
+	return &cp, nil
+}
+
+func (s *memRefreshStore) RevokeFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rt := range s.items {
+		if rt.Family == family {
+			rt.Revoked = true
+		}
+	}
+	return nil
+}
+
+// TokenIssuer issue access/refresh token pairs for API clients, the access
+// tokens are signed User with a short Expiry, the refresh tokens rotate on
+// every use and a reused one revokes the whole family
+type TokenIssuer struct {
+	AccessTTL  time.Duration // default 15 minutes
+	RefreshTTL time.Duration // default 30 days
+	Users      UserGetter    // look up users again on refresh, keep the stored one if nil
+
+	a     Authorizer
+	store RefreshStore
+}
+
+// NewTokenIssuer return a TokenIssuer signs tokens by a, it requires WithSecret
+func NewTokenIssuer(a Authorizer, store RefreshStore) *TokenIssuer {
+	if store == nil {
+		store = NewRefreshStore()
+	}
+	return &TokenIssuer{
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 30 * 24 * time.Hour,
+		a:          a,
+		store:      store,
+	}
+}
+
+// Issue a new token pair of user, e.g. after login, the pair expires by the Expiry
+// of user if set, e.g. a narrowed child token
+func (ti *TokenIssuer) Issue(ctx context.Context, user *User) (*TokenResponse, error) {
+	return ti.issue(ctx, *user, randString(16))
+}
+
+// Refresh exchange a refresh token for a new pair
+func (ti *TokenIssuer) Refresh(ctx context.Context, token string) (*TokenResponse, error) {
+	return ti.refresh(ctx, token, "")
+}
+
+// refresh exchange a refresh token issued to client, empty for first-party
+func (ti *TokenIssuer) refresh(ctx context.Context, token, client string) (*TokenResponse, error) {
+	rt, err := ti.store.Use(ctx, tokenHash(token))
+	if err != nil {
+		return nil, err
+	}
+	if rt == nil || rt.Revoked || rt.Expires.Before(time.Now()) || rt.User.Client != client {
+		return nil, ErrInvalidRefresh
+	}
+	if rt.Used {
+		slog.Warn("refresh token reused", "uid", rt.User.UID, "family", rt.Family)
+		if err = ti.store.RevokeFamily(ctx, rt.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshReused
+	}
+	user := rt.User
+	if ti.Users != nil {
+		iu, err := ti.Users.GetUser(ctx, user.UID)
+		if err != nil {
+			slog.Info("refresh user fail", "uid", user.UID, "err", err)
+			return nil, ErrInvalidRefresh
+		}
+		// the profile of ToUser is replaced, other claims of the token are kept,
+		// e.g. methods, client, scopes, path, teams, extra, binding, actor and expiry
+		fresh := ToUser(iu)
+		user.OID, user.UID, user.Name, user.Avatar = fresh.OID, fresh.UID, fresh.Name, fresh.Avatar
+		user.TeamID, user.Roles, user.Watchings = fresh.TeamID, fresh.Roles, fresh.Watchings
+	}
+	return ti.issue(ctx, user, rt.Family)
+}
+
+// revoke the family of a refresh token issued to client, return false if not found
+func (ti *TokenIssuer) revoke(ctx context.Context, token, client string) (bool, error) {
+	rt, err := ti.store.Use(ctx, tokenHash(token))
+	if err != nil || rt == nil {
+		return false, err
+	}
+	if rt.User.Client != client {
+		return true, ErrInvalidRefresh
+	}
+	return true, ti.store.RevokeFamily(ctx, rt.Family)
+}
+
+func (ti *TokenIssuer) issue(ctx context.Context, user User, family string) (*TokenResponse, error) {
+	res, err := ti.access(user)
+	if err != nil {
+		return nil, err
+	}
+	res.RefreshToken = randString(32)
+	expires := time.Now().Add(ti.RefreshTTL)
+	if user.Expiry > 0 && user.Expiry < expires.Unix() {
+		expires = time.Unix(user.Expiry, 0)
+	}
+	err = ti.store.Save(ctx, &RefreshToken{
+		ID:      tokenHash(res.RefreshToken),
+		Family:  family,
+		User:    user,
+		Expires: expires,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// access return a signed access token of user only, it never outlives the Expiry of user
+func (ti *TokenIssuer) access(user User) (*TokenResponse, error) {
+	tc, err := codecOf(ti.a, true)
+	if err != nil {
+		return nil, err
+	}
+	user.Refresh()
+	exp := user.LastHit + int64(ti.AccessTTL/time.Second)
+	if user.Expiry > 0 {
+		exp = min(exp, user.Expiry)
+	}
+	user.Expiry = exp
+	token, err := tc.encodeToken(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   exp - user.LastHit,
+		Scope:       strings.Join(user.Scopes, " "),
+	}, nil
+}
+
+// RefreshHandler handle POST form with refresh_token, return a new pair in JSON
+func (ti *TokenIssuer) RefreshHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		res, err := ti.Refresh(r.Context(), r.PostFormValue("refresh_token"))
+		if err != nil {
+			slog.Info("refresh token fail", "err", err)
+			if errors.Is(err, ErrInvalidRefresh) || errors.Is(err, ErrRefreshReused) {
+				writeJSON(w, http.StatusUnauthorized, oauthErr("invalid_grant", err.Error()))
+			} else {
+				writeJSON(w, http.StatusInternalServerError, oauthErr("server_error", err.Error()))
+			}
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, res)
+	})
+}
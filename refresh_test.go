@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenIssuer(t *testing.T) {
+	ctx := context.Background()
+	a := New(WithSecret(testSecret))
+	ti := NewTokenIssuer(a, nil)
+	ti.AccessTTL = time.Minute
+
+	res, err := ti.Issue(ctx, &User{UID: "alice", Roles: Names{"dev"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(60), res.ExpiresIn)
+
+	u, err := a.(*option).parseToken(res.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", u.UID)
+	assert.False(t, u.IsExpired())
+	assert.Equal(t, u.Expiry, u.ExpiresAt())
+
+	res2, err := ti.Refresh(ctx, res.RefreshToken)
+	assert.Nil(t, err)
+	assert.NotEqual(t, res.RefreshToken, res2.RefreshToken)
+
+	// reuse of a rotated token revokes the family
+	_, err = ti.Refresh(ctx, res.RefreshToken)
+	assert.Equal(t, ErrRefreshReused, err)
+	_, err = ti.Refresh(ctx, res2.RefreshToken)
+	assert.Equal(t, ErrInvalidRefresh, err)
+
+	_, err = ti.Refresh(ctx, "unknown")
+	assert.Equal(t, ErrInvalidRefresh, err)
+
+	// tokens of OAuth2 clients are not first-party
+	res, _ = ti.Issue(ctx, &User{UID: "alice", Client: "spa"})
+	_, err = ti.Refresh(ctx, res.RefreshToken)
+	assert.Equal(t, ErrInvalidRefresh, err)
+
+	// users are looked up again
+	ti.Users = mockUsers{"alice": {uid: "alice", name: "Alice"}}
+	res, _ = ti.Issue(ctx, &User{UID: "alice", Roles: Names{"dev"}, AuthMeths: Names{AmrPassword}})
+	res, err = ti.Refresh(ctx, res.RefreshToken)
+	assert.Nil(t, err)
+	u, _ = a.(*option).parseToken(res.AccessToken)
+	assert.Equal(t, "Alice", u.Name)
+	assert.Empty(t, u.Roles)
+	assert.Equal(t, Names{AmrPassword}, u.AuthMeths)
+
+	// other claims are kept, the parent expiry too
+	parent := time.Now().Add(10 * time.Minute).Unix()
+	res, _ = ti.Issue(ctx, &User{UID: "alice", Path: "/api", Teams: Teams{{TeamID: 7, Roles: Names{"admin"}}},
+		Extra: Attrs{"k": "v"}, Actor: &Actor{UID: "root"}, Origin: "o", Expiry: parent})
+	res, err = ti.Refresh(ctx, res.RefreshToken)
+	assert.Nil(t, err)
+	u, _ = a.(*option).parseToken(res.AccessToken)
+	assert.Equal(t, "Alice", u.Name)
+	assert.Equal(t, "/api", u.Path)
+	assert.Equal(t, Names{"admin"}, u.RolesIn(7))
+	assert.Equal(t, "v", u.Extra.GetString("k"))
+	assert.Equal(t, "root", u.Actor.UID)
+	assert.Equal(t, "o", u.Origin)
+	assert.LessOrEqual(t, u.Expiry, parent)
+
+	delete(ti.Users.(mockUsers), "alice")
+	_, err = ti.Refresh(ctx, res.RefreshToken)
+	assert.Equal(t, ErrInvalidRefresh, err)
+
+	// a child token never outlives its parent
+	ti.Users = nil
+	parent = time.Now().Add(30 * time.Second).Unix()
+	res, _ = ti.Issue(ctx, &User{UID: "alice", Scopes: Names{"read"}, Expiry: parent})
+	assert.LessOrEqual(t, res.ExpiresIn, int64(30))
+	u, _ = a.(*option).parseToken(res.AccessToken)
+	assert.Equal(t, parent, u.Expiry)
+	res, _ = ti.Issue(ctx, &User{UID: "alice", Expiry: time.Now().Unix() - 1})
+	_, err = ti.Refresh(ctx, res.RefreshToken)
+	assert.Equal(t, ErrInvalidRefresh, err)
+
+	// no secret
+	_, err = NewTokenIssuer(New(), nil).Issue(ctx, &User{UID: "alice"})
+	assert.Equal(t, ErrNoSecret, err)
+}
+
+func TestUserExpiry(t *testing.T) {
+	u := &User{}
+	u.Refresh()
+	u.Expiry = time.Now().Unix() - 1
+	assert.True(t, u.IsExpired())
+	u.Expiry = time.Now().Unix() + 60
+	assert.False(t, u.IsExpired())
+}
+
+func TestRefreshHandler(t *testing.T) {
+	a := New(WithSecret(testSecret))
+	ti := NewTokenIssuer(a, nil)
+	res, _ := ti.Issue(context.Background(), &User{UID: "alice"})
+
+	w := postForm(ti.RefreshHandler(), url.Values{"refresh_token": {res.RefreshToken}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got TokenResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+got.AccessToken)
+	user, err := a.UserFromRequest(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", user.UID)
+
+	w = postForm(ti.RefreshHandler(), url.Values{"refresh_token": {"bad"}})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked ...
+var ErrTokenRevoked = errors.New("token is revoked")
+
+// Revoker keep revoked tokens until they expire
+type Revoker interface {
+	Revoke(token string, exp time.Time)
+	IsRevoked(token string) bool
+}
+
+type memRevoker struct {
+	mu    sync.RWMutex
+	items map[string]time.Time
+}
+
+// NewRevoker return an in-memory Revoker
+func NewRevoker() Revoker {
+	return &memRevoker{items: make(map[string]time.Time)}
+}
+
+func (r *memRevoker) Revoke(token string, exp time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if len(r.items) > 1000 {
+		for k, t := range r.items {
+			if t.Before(now) {
+				delete(r.items, k)
+			}
+		}
+	}
+	r.items[tokenHash(token)] = exp
+}
+
+func (r *memRevoker) IsRevoked(token string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.items[tokenHash(token)]
+	return ok
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
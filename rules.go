@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"net/http"
+	"path"
+)
+
+// Access the access level of a route
+type Access int
+
+// access levels
+const (
+	AccessAuthenticated Access = iota // a user is required, default
+	AccessPublic                      // no user is needed
+	AccessOptional                    // the user is put into context if present
+	AccessRoles                       // a user with one of roles is required
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessPublic:
+		return "public"
+	case AccessOptional:
+		return "optional"
+	case AccessRoles:
+		return "roles"
+	}
+	return "authenticated"
+}
+
+// MarshalText ...
+func (a Access) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// Rule the access of a route pattern
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Access  Access `json:"access"`
+	Roles   Names  `json:"roles,omitempty"`
+}
+
+// rule a route in the matcher
+type rule struct {
+	Rule
+}
+
+func (rule) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+// Rules a table of path rules evaluated by one middleware, patterns are of
+// http.ServeMux (Go 1.22), e.g. "GET /static/", "/admin/{$}", unmatched
+// routes require authentication
+type Rules struct {
+	a     Authorizer
+	mux   *http.ServeMux
+	rules []Rule
+}
+
+// NewRules return an empty rule table of a
+func NewRules(a Authorizer) *Rules {
+	return &Rules{a: a, mux: http.NewServeMux()}
+}
+
+// Add a rule, it panics on a conflicting pattern like http.ServeMux
+func (rs *Rules) Add(pattern string, access Access, roles ...string) *Rules {
+	r := Rule{Pattern: pattern, Access: access, Roles: roles}
+	rs.mux.Handle(pattern, rule{r})
+	rs.rules = append(rs.rules, r)
+	return rs
+}
+
+// Public allow anonymous access of patterns
+func (rs *Rules) Public(patterns ...string) *Rules {
+	for _, p := range patterns {
+		rs.Add(p, AccessPublic)
+	}
+	return rs
+}
+
+// Optional put the user into context if present
+func (rs *Rules) Optional(patterns ...string) *Rules {
+	for _, p := range patterns {
+		rs.Add(p, AccessOptional)
+	}
+	return rs
+}
+
+// Authenticated require a user, same as unmatched routes
+func (rs *Rules) Authenticated(patterns ...string) *Rules {
+	for _, p := range patterns {
+		rs.Add(p, AccessAuthenticated)
+	}
+	return rs
+}
+
+// Roles require a user with one of roles
+func (rs *Rules) Roles(pattern string, roles ...string) *Rules {
+	return rs.Add(pattern, AccessRoles, roles...)
+}
+
+// Match return the rule of request, an unclean path (e.g. /admin//x or /static/../admin/x)
+// gets the rule of the clean one, which ServeMux redirects to and other routers may serve
+func (rs *Rules) Match(r *http.Request) Rule {
+	if ru, ok := rs.match(r); ok {
+		return ru
+	}
+	if p := cleanPath(r.URL.Path); p != r.URL.Path {
+		cr, u := *r, *r.URL
+		u.Path, u.RawPath = p, ""
+		cr.URL = &u
+		if ru, ok := rs.match(&cr); ok {
+			return ru
+		}
+	}
+	return Rule{Access: AccessAuthenticated}
+}
+
+func (rs *Rules) match(r *http.Request) (Rule, bool) {
+	if h, _ := rs.mux.Handler(r); h != nil {
+		if ru, ok := h.(rule); ok {
+			return ru.Rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// cleanPath return the canonical path like ServeMux, with the trailing slash kept
+func cleanPath(p string) string {
+	if len(p) == 0 || p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+// Middleware ...
+func (rs *Rules) Middleware() func(next http.Handler) http.Handler {
+	return rs.MiddlewareWordy(false)
+}
+
+// MiddlewareWordy evaluate the rule of every request
+func (rs *Rules) MiddlewareWordy(redir bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		mw := rs.a.MiddlewareWordy(redir)
+		authed := mw(next)
+		guarded := make(map[string]http.Handler)
+		for _, ru := range rs.rules {
+			if ru.Access == AccessRoles {
+				guarded[ru.Pattern] = mw(RequireRoles(ru.Roles...)(next))
+			}
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			ru := rs.Match(req)
+			switch ru.Access {
+			case AccessPublic:
+				next.ServeHTTP(rw, req)
+			case AccessOptional:
+				if user, err := rs.a.UserFromRequest(req); err == nil {
+					req = req.WithContext(ContextWithUser(req.Context(), user))
+				}
+				next.ServeHTTP(rw, req)
+			case AccessRoles:
+				h, ok := guarded[ru.Pattern]
+				if !ok { // added after the middleware
+					h = mw(RequireRoles(ru.Roles...)(next))
+				}
+				h.ServeHTTP(rw, req)
+			default:
+				authed.ServeHTTP(rw, req)
+			}
+		})
+	}
+}
+
+// DebugHandler dump the rules in JSON, the last one is the default of unmatched routes
+func (rs *Rules) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list := append(append([]Rule(nil), rs.rules...), Rule{Pattern: "*", Access: AccessAuthenticated})
+		writeJSON(w, http.StatusOK, list)
+	})
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRules(t *testing.T) {
+	a := New(WithURI("/login"))
+	rs := NewRules(a).
+		Public("/healthz", "GET /static/", "/login").
+		Optional("GET /{$}").
+		Roles("/admin/", "admin").
+		Authenticated("POST /static/upload")
+
+	h := rs.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := UserFromContext(r.Context()); ok {
+			_, _ = io.WriteString(w, user.UID)
+			return
+		}
+		_, _ = io.WriteString(w, "anonymous")
+	}))
+
+	alice := &User{UID: "alice"}
+	alice.Refresh()
+	aliceToken, _ := alice.Encode()
+	root := &User{UID: "root", Roles: Names{"admin"}}
+	root.Refresh()
+	rootToken, _ := root.Encode()
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "anonymous", do(http.MethodGet, "/healthz", "").Body.String())
+	assert.Equal(t, "anonymous", do(http.MethodGet, "/static/app.js", "").Body.String())
+	assert.Equal(t, "anonymous", do(http.MethodHead, "/static/app.js", "").Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/static/app.js", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/static/upload", "").Code)
+
+	assert.Equal(t, "anonymous", do(http.MethodGet, "/", "").Body.String())
+	assert.Equal(t, "alice", do(http.MethodGet, "/", aliceToken).Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/profile", "").Code)
+	assert.Equal(t, "alice", do(http.MethodGet, "/profile", aliceToken).Body.String())
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/admin/users", aliceToken).Code)
+	assert.Equal(t, "root", do(http.MethodGet, "/admin/users", rootToken).Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/admin/users", "").Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/x", nil)
+	assert.Equal(t, Rule{Pattern: "/admin/", Access: AccessRoles, Roles: Names{"admin"}}, rs.Match(req))
+
+	// unclean paths get the rule of the clean one
+	for _, p := range []string{"/admin//x", "/static/../admin/x", "/static/./../admin/", "//admin/x"} {
+		assert.Equal(t, "/admin/", rs.Match(httptest.NewRequest(http.MethodGet, p, nil)).Pattern, p)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, p, aliceToken).Code, p)
+	}
+	assert.Equal(t, "anonymous", do(http.MethodGet, "/static//app.js", "").Body.String())
+
+	w := httptest.NewRecorder()
+	rs.DebugHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/auth", nil))
+	var dump []map[string]any
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&dump))
+	assert.Len(t, dump, 7)
+	assert.Equal(t, "GET /static/", dump[1]["pattern"])
+	assert.Equal(t, "public", dump[1]["access"])
+	assert.Equal(t, "roles", dump[4]["access"])
+	assert.Equal(t, "*", dump[6]["pattern"])
+	assert.Equal(t, "authenticated", dump[6]["access"])
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// vars
+var (
+	ErrScopeEscalation = errors.New("scopes exceed the parent token")
+	ErrPathEscalation  = errors.New("path exceeds the parent token")
+	ErrPathRestricted  = errors.New("token is restricted to another path")
+)
+
+// HasScope checks if the user is granted scope, a user without Scopes has full access
+func (u *User) HasScope(scope string) bool {
+	return len(u.Scopes) == 0 || u.Scopes.Has(scope)
+}
+
+// AllowPath checks the path restriction of token
+func (u *User) AllowPath(path string) bool {
+	if len(u.Path) == 0 {
+		return true
+	}
+	return path == u.Path || strings.HasPrefix(path, strings.TrimSuffix(u.Path, "/")+"/")
+}
+
+// Narrow return a child with fewer scopes, shorter lifetime and optional path restriction,
+// it never carries more privilege than u
+func (u *User) Narrow(scopes []string, lifetime time.Duration, path string) (*User, error) {
+	if len(scopes) == 0 {
+		return nil, ErrScopeEscalation
+	}
+	for _, s := range scopes {
+		if !u.HasScope(s) {
+			return nil, ErrScopeEscalation
+		}
+	}
+	if len(path) == 0 {
+		path = u.Path
+	} else if !u.AllowPath(path) {
+		return nil, ErrPathEscalation
+	}
+
+	child := *u
+	child.Scopes = append(Names(nil), scopes...)
+	child.Path = path
+	child.Refresh()
+	child.Expiry = time.Now().Add(lifetime).Unix()
+	if DefaultLifetime > 0 || u.Expiry > 0 {
+		child.Expiry = min(child.Expiry, u.ExpiresAt())
+	}
+	return &child, nil
+}
+
+// RequireScopes require the user is granted all scopes, use after Middleware
+func RequireScopes(scopes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			user, ok := UserFromContext(req.Context())
+			if !ok {
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			for _, s := range scopes {
+				if !user.HasScope(s) {
+					slog.Info("scope denied", "uid", user.UID, "want", s, "have", user.Scopes)
+					rw.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+					http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// NarrowHandler mint a down-scoped child token of current session, POST form:
+// scope (space separated), ttl (Go duration, default 1h), path (optional).
+// It requires WithSecret of a, otherwise the restrictions could be stripped
+func NarrowHandler(a Authorizer) http.Handler {
+	tc, cerr := codecOf(a, true)
+	if cerr != nil {
+		slog.Warn("narrow tokens without signing", "err", cerr)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if cerr != nil {
+			writeJSON(w, http.StatusInternalServerError, oauthErr("server_error", cerr.Error()))
+			return
+		}
+		user, err := a.UserFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		ttl := time.Hour
+		if s := r.PostFormValue("ttl"); s != "" {
+			if ttl, err = time.ParseDuration(s); err != nil || ttl <= 0 {
+				writeJSON(w, http.StatusBadRequest, oauthErr("invalid_request", "invalid ttl"))
+				return
+			}
+		}
+		child, err := user.Narrow(strings.Fields(r.PostFormValue("scope")), ttl, r.PostFormValue("path"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, oauthErr("invalid_scope", err.Error()))
+			return
+		}
+		token, err := tc.encodeToken(child)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, oauthErr("server_error", err.Error()))
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, &TokenResponse{
+			AccessToken: token,
+			TokenType:   "Bearer",
+			ExpiresIn:   child.Expiry - time.Now().Unix(),
+			Scope:       strings.Join(child.Scopes, " "),
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNarrow(t *testing.T) {
+	u := &User{UID: "alice", Roles: Names{"admin"}}
+	u.Refresh()
+	assert.True(t, u.HasScope("anything"))
+
+	child, err := u.Narrow([]string{"read", "write"}, time.Hour, "/api/")
+	assert.Nil(t, err)
+	assert.Equal(t, Names{"read", "write"}, child.Scopes)
+	assert.Equal(t, "/api/", child.Path)
+	assert.Empty(t, u.Scopes)
+
+	grand, err := child.Narrow([]string{"read"}, time.Minute, "/api/docs")
+	assert.Nil(t, err)
+	assert.True(t, grand.HasScope("read"))
+	assert.False(t, grand.HasScope("write"))
+	assert.LessOrEqual(t, grand.Expiry, child.Expiry)
+
+	_, err = grand.Narrow([]string{"write"}, time.Minute, "")
+	assert.Equal(t, ErrScopeEscalation, err)
+	_, err = grand.Narrow(nil, time.Minute, "")
+	assert.Equal(t, ErrScopeEscalation, err)
+	_, err = grand.Narrow([]string{"read"}, time.Minute, "/admin")
+	assert.Equal(t, ErrPathEscalation, err)
+
+	// a longer lifetime is capped by the parent
+	long, err := child.Narrow([]string{"read"}, 24*time.Hour, "")
+	assert.Nil(t, err)
+	assert.Equal(t, child.Expiry, long.Expiry)
+	assert.Equal(t, "/api/", long.Path)
+
+	assert.True(t, grand.AllowPath("/api/docs"))
+	assert.True(t, grand.AllowPath("/api/docs/1"))
+	assert.False(t, grand.AllowPath("/api/docsx"))
+	assert.False(t, grand.AllowPath("/api"))
+}
+
+func TestRequireScopes(t *testing.T) {
+	h := RequireScopes("read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	bg := context.Background()
+	assert.Equal(t, http.StatusOK, do(ContextWithUser(bg, &User{UID: "full"})).Code)
+	assert.Equal(t, http.StatusOK, do(ContextWithUser(bg, &User{UID: "r", Scopes: Names{"read"}})).Code)
+	w := do(ContextWithUser(bg, &User{UID: "w", Scopes: Names{"write"}}))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "insufficient_scope")
+	assert.Equal(t, http.StatusUnauthorized, do(bg).Code)
+}
+
+func TestNarrowHandler(t *testing.T) {
+	a := New(WithSecret(testSecret))
+	u := &User{UID: "alice"}
+	u.Refresh()
+	token := signToken(t, a, u)
+
+	h := NarrowHandler(a)
+	do := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/narrow", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(url.Values{"scope": {"read"}, "ttl": {"10m"}, "path": {"/api/"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var tr TokenResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&tr))
+	assert.LessOrEqual(t, tr.ExpiresIn, int64(600))
+
+	assert.Equal(t, "read", tr.Scope)
+	child, err := a.(*option).parseToken(tr.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, Names{"read"}, child.Scopes)
+
+	// the restrictions can't be stripped
+	stripped := *child
+	stripped.Scopes, stripped.Path = nil, ""
+	s, _ := stripped.Encode()
+	_, err = a.(*option).parseToken(s + tr.AccessToken[strings.LastIndexByte(tr.AccessToken, '.'):])
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	assert.Equal(t, http.StatusBadRequest, do(url.Values{"scope": {"read"}, "ttl": {"-1s"}}).Code)
+	assert.Equal(t, http.StatusBadRequest, do(url.Values{}).Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/narrow", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	// the child token is rejected outside its path
+	req = httptest.NewRequest(http.MethodGet, "/api/items", nil)
+	req.Header.Set("Authorization", "Bearer "+tr.AccessToken)
+	_, err = a.UserFromRequest(req)
+	assert.Nil(t, err)
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+tr.AccessToken)
+	_, err = a.UserFromRequest(req)
+	assert.Equal(t, ErrPathRestricted, err)
+
+	// a path-restricted token is active in introspection, with its scope and path
+	w = postForm(IntrospectHandler(a, ClientMap{"rs": {ID: "rs", Secret: "s3cret"}}),
+		url.Values{"client_id": {"rs"}, "client_secret": {"s3cret"}, "token": {tr.AccessToken}})
+	var res Introspection
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.True(t, res.Active)
+	assert.Equal(t, "read", res.Scope)
+	assert.Equal(t, "/api/", res.Path)
+
+	// no secret
+	w = postForm(NarrowHandler(New()), url.Values{"scope": {"read"}})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
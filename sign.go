@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// vars
+var (
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrNoSecret         = errors.New("a secret is required, see WithSecret")
+)
+
+// WithSecret The option sign tokens with HMAC-SHA256 of the first key, tokens signed
+// by any of keys are accepted (key rotation), unsigned tokens are rejected.
+// Without it tokens are unsigned as before and every claim can be forged by clients
+func WithSecret(keys ...[]byte) OptFunc {
+	return func(opt *option) {
+		opt.Secrets = nil
+		for _, k := range keys {
+			if len(k) > 0 {
+				opt.Secrets = append(opt.Secrets, k)
+			}
+		}
+	}
+}
+
+// tokenCodec sign and verify tokens, implemented by authorizers of this package
+type tokenCodec interface {
+	signed() bool
+	encodeToken(user Encoder) (string, error)
+	parseToken(token string) (*User, error)
+	deriveKeys(label string) [][]byte
+}
+
+// codecOf return the tokenCodec of a, strict requires a secret
+func codecOf(a Authorizer, strict bool) (tokenCodec, error) {
+	tc, ok := a.(tokenCodec)
+	if !ok || (strict && !tc.signed()) {
+		return nil, ErrNoSecret
+	}
+	return tc, nil
+}
+
+func (opt *option) signed() bool {
+	return len(opt.Secrets) > 0
+}
+
+// deriveKeys return a key of every secret for label, the first signs, so that
+// other tokens never share the keys of sessions
+func (opt *option) deriveKeys(label string) [][]byte {
+	keys := make([][]byte, len(opt.Secrets))
+	for i, secret := range opt.Secrets {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(label))
+		keys[i] = mac.Sum(nil)
+	}
+	return keys
+}
+
+func sign(payload string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encodeToken encode user, append the signature if signed: payload.signature
+func (opt *option) encodeToken(user Encoder) (string, error) {
+	s, err := user.Encode()
+	if err != nil || !opt.signed() {
+		return s, err
+	}
+	return s + "." + sign(s, opt.Secrets[0]), nil
+}
+
+// verifyToken return the payload of token if the signature is valid
+func (opt *option) verifyToken(token string) (string, error) {
+	if !opt.signed() {
+		return token, nil
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", ErrInvalidSignature
+	}
+	payload, sig := token[:i], token[i+1:]
+	for _, key := range opt.Secrets {
+		if hmac.Equal([]byte(sig), []byte(sign(payload, key))) {
+			return payload, nil
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+// parseToken check revocation and signature, decode the user and check expiry
+func (opt *option) parseToken(token string) (*User, error) {
+	payload, err := opt.checkToken(token)
+	if err != nil {
+		return nil, err
+	}
+	user := new(User)
+	if err = user.Decode(payload); err != nil {
+		slog.Info("decode fail", "token", token, "err", err)
+		return nil, err
+	}
+	if user.IsExpired() {
+		slog.Info("expired", "token", token, "uid", user.UID)
+		return nil, fmt.Errorf("user %s is expired", user.UID)
+	}
+	return user, nil
+}
+
+// checkToken check revocation and signature, return the payload
+func (opt *option) checkToken(token string) (string, error) {
+	if opt.Revoker != nil && opt.Revoker.IsRevoked(token) {
+		slog.Info("revoked", "token", token)
+		return "", ErrTokenRevoked
+	}
+	payload, err := opt.verifyToken(token)
+	if err != nil {
+		slog.Info("verify fail", "token", token, "err", err)
+	}
+	return payload, err
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// signToken encode user as the Signin of a
+func signToken(t *testing.T, a Authorizer, user Encoder) string {
+	tc, err := codecOf(a, false)
+	assert.Nil(t, err)
+	token, err := tc.encodeToken(user)
+	assert.Nil(t, err)
+	return token
+}
+
+func TestSignedToken(t *testing.T) {
+	a := New(WithSecret(testSecret))
+	user := &User{UID: "alice", Roles: Names{"viewer"}, Scopes: Names{"read"}, Path: "/api/"}
+	user.Refresh()
+
+	w := httptest.NewRecorder()
+	assert.Nil(t, a.Signin(user, w))
+	token := w.Result().Cookies()[0].Value
+	assert.Contains(t, token, ".")
+
+	from := func(a Authorizer, token string) (*User, error) {
+		req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return a.UserFromRequest(req)
+	}
+	got, err := from(a, token)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", got.UID)
+
+	// strip restrictions and escalate, with the old signature or none
+	forged := *user
+	forged.Scopes, forged.Path, forged.Roles = nil, "", Names{"admin"}
+	forged.Authenticated(AmrOTP, AmrMFA)
+	payload, _ := forged.Encode()
+	sig := token[strings.LastIndexByte(token, '.'):]
+	for _, s := range []string{payload, payload + sig, payload + ".", token + "x"} {
+		_, err = from(a, s)
+		assert.Equal(t, ErrInvalidSignature, err, s)
+	}
+
+	// key rotation
+	rotated := New(WithSecret([]byte("new key"), testSecret))
+	_, err = from(rotated, token)
+	assert.Nil(t, err)
+	_, err = from(New(WithSecret([]byte("other"))), token)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	// unsigned as before without a secret
+	legacy := New()
+	_, err = from(legacy, payload)
+	assert.Nil(t, err)
+	_, err = codecOf(legacy, true)
+	assert.Equal(t, ErrNoSecret, err)
+}
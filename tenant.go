@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TenantResolver return the tenant key of request, e.g. a slug or an ID
+type TenantResolver func(r *http.Request) string
+
+// TenantFromPath resolve tenant from a path wildcard of http.ServeMux, e.g. /t/{tenant}/
+func TenantFromPath(name string) TenantResolver {
+	return func(r *http.Request) string {
+		return r.PathValue(name)
+	}
+}
+
+// TenantFromHeader resolve tenant from a request header, e.g. X-Tenant
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// TenantFromSubdomain resolve tenant from the first label of host under domain,
+// e.g. acme.example.com with domain example.com
+func TenantFromSubdomain(domain string) TenantResolver {
+	suffix := "." + strings.TrimPrefix(domain, ".")
+	return func(r *http.Request) string {
+		sub, ok := strings.CutSuffix(hostname(r), suffix)
+		if !ok || strings.Contains(sub, ".") {
+			return ""
+		}
+		return sub
+	}
+}
+
+// TenantAudit called when a super-admin accesses another tenant
+type TenantAudit func(r *http.Request, user *User, tid int64)
+
+type tenantOption struct {
+	Lookup     func(ctx context.Context, key string) (int64, error)
+	SuperRoles Names
+	Audit      TenantAudit
+}
+
+// TenantOptFunc ...
+type TenantOptFunc func(opt *tenantOption)
+
+// WithTenantLookup set a func map tenant key to team ID, default: parse key as integer
+func WithTenantLookup(fn func(ctx context.Context, key string) (int64, error)) TenantOptFunc {
+	return func(opt *tenantOption) {
+		if fn != nil {
+			opt.Lookup = fn
+		}
+	}
+}
+
+// WithSuperRoles set roles allowed to cross tenants
+func WithSuperRoles(roles ...string) TenantOptFunc {
+	return func(opt *tenantOption) {
+		opt.SuperRoles = roles
+	}
+}
+
+// WithTenantAudit set the hook of cross-tenant access
+func WithTenantAudit(fn TenantAudit) TenantOptFunc {
+	return func(opt *tenantOption) {
+		opt.Audit = fn
+	}
+}
+
+// TenantMiddleware resolve the tenant of request, reject users not in the team with 403,
+// put the tenant into context, use after Middleware of a. Teams are claims of the token,
+// it needs signed tokens (WithSecret of a), requests are rejected without
+func TenantMiddleware(a Authorizer, resolve TenantResolver, opts ...TenantOptFunc) func(next http.Handler) http.Handler {
+	to := &tenantOption{
+		Lookup: func(_ context.Context, key string) (int64, error) {
+			return strconv.ParseInt(key, 10, 64)
+		},
+	}
+	for _, fn := range opts {
+		fn(to)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if _, err := codecOf(a, true); err != nil {
+				slog.Warn("tenant scoping without signed tokens", "err", err)
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			user, ok := UserFromContext(req.Context())
+			if !ok {
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			key := resolve(req)
+			if len(key) == 0 {
+				http.Error(rw, "tenant is required", http.StatusBadRequest)
+				return
+			}
+			tid, err := to.Lookup(req.Context(), key)
+			if err == nil && tid <= 0 {
+				err = errors.New("invalid team id")
+			}
+			if err != nil {
+				slog.Info("lookup tenant fail", "key", key, "err", err)
+				http.Error(rw, "tenant not found", http.StatusNotFound)
+				return
+			}
+			if !user.InTeam(tid) {
+				if !user.HasAnyRole(to.SuperRoles...) {
+					slog.Info("cross tenant denied", "uid", user.UID, "tid", tid)
+					http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+				if to.Audit != nil {
+					to.Audit(req, user, tid)
+				}
+				req = req.WithContext(context.WithValue(req.Context(), crossKey, true))
+			}
+			req = req.WithContext(ContextWithTenant(req.Context(), tid))
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// RolesInContext return roles of user in the tenant of ctx (see TenantMiddleware),
+// the global Roles without a tenant or for a super-admin crossed into it
+func RolesInContext(ctx context.Context, user *User) Names {
+	tid, ok := TenantFromContext(ctx)
+	if !ok {
+		return user.Roles
+	}
+	if crossed, _ := ctx.Value(crossKey).(bool); crossed {
+		return user.Roles
+	}
+	return user.RolesIn(tid)
+}
+
+// RequireRoles require the user has one of roles, in the current tenant if
+// TenantMiddleware resolved one, otherwise (or for a super-admin crossed in) in the global Roles,
+// use after Middleware
+func RequireRoles(roles ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			user, ok := UserFromContext(req.Context())
+			if !ok {
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			have := RolesInContext(req.Context(), user)
+			for _, r := range roles {
+				if have.Has(r) {
+					next.ServeHTTP(rw, req)
+					return
+				}
+			}
+			slog.Info("role denied", "uid", user.UID, "want", roles, "have", have)
+			http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantResolvers(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://acme.example.com:8080/x", nil)
+	assert.Equal(t, "acme", TenantFromSubdomain("example.com")(req))
+	req = httptest.NewRequest(http.MethodGet, "http://a.b.example.com/x", nil)
+	assert.Empty(t, TenantFromSubdomain("example.com")(req))
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/x", nil)
+	assert.Empty(t, TenantFromSubdomain("example.com")(req))
+
+	req.Header.Set("X-Tenant", "12")
+	assert.Equal(t, "12", TenantFromHeader("X-Tenant")(req))
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	slugs := map[string]int64{"acme": 1, "globex": 2, "none": 0}
+	var audited []int64
+	a := New(WithSecret(testSecret))
+	mw := TenantMiddleware(a, TenantFromPath("tenant"),
+		WithTenantLookup(func(_ context.Context, key string) (int64, error) {
+			if id, ok := slugs[key]; ok {
+				return id, nil
+			}
+			return 0, errors.New("not found")
+		}),
+		WithSuperRoles("root"),
+		WithTenantAudit(func(r *http.Request, u *User, tid int64) { audited = append(audited, tid) }),
+	)
+	mux := http.NewServeMux()
+	mux.Handle("/t/{tenant}/", mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := TenantFromContext(r.Context())
+		assert.True(t, ok)
+		w.Header().Set("X-Tid", strconv.FormatInt(tid, 10))
+	})))
+	mux.Handle("/t/{tenant}/admin", mw(RequireRoles("root", "admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+
+	do := func(user *User, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if user != nil {
+			req = req.WithContext(ContextWithUser(req.Context(), user))
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	member := &User{UID: "alice", TeamID: 1}
+	w := do(member, "/t/acme/home")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Tid"))
+
+	assert.Equal(t, http.StatusForbidden, do(member, "/t/globex/home").Code)
+	assert.Equal(t, http.StatusNotFound, do(member, "/t/initech/home").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(nil, "/t/acme/home").Code)
+
+	root := &User{UID: "root", TeamID: 1, Roles: Names{"root"}}
+	assert.Equal(t, http.StatusOK, do(root, "/t/globex/home").Code)
+	assert.Equal(t, []int64{2}, audited)
+	// a super-admin crossed in keeps the global roles
+	assert.Equal(t, http.StatusOK, do(root, "/t/globex/admin").Code)
+	assert.Equal(t, http.StatusForbidden, do(member, "/t/acme/admin").Code)
+
+	// 0 is no team, users without a team never match it
+	assert.Equal(t, http.StatusNotFound, do(&User{UID: "bob"}, "/t/none/home").Code)
+	assert.Equal(t, http.StatusNotFound, do(&User{UID: "bob"}, "/t/0/home").Code)
+
+	// teams of unsigned tokens can be forged
+	mw = TenantMiddleware(New(), TenantFromPath("tenant"))
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/t/1/home", nil)
+	mw(http.NotFoundHandler()).ServeHTTP(w, req.WithContext(ContextWithUser(req.Context(), member)))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestRequireRoles(t *testing.T) {
+	u := &User{UID: "alice", TeamID: 1, Roles: Names{"admin"}}
+	u.SetTeamRoles(2, "viewer")
+	u.SetTeamRoles(3, "editor")
+	u.SetTeamRoles(3, "owner")
+
+	assert.True(t, u.InTeam(1))
+	assert.True(t, u.InTeam(2))
+	assert.False(t, u.InTeam(4))
+	assert.Equal(t, Names{"admin"}, u.RolesIn(1))
+	assert.True(t, u.HasRoleIn(2, "viewer"))
+	assert.False(t, u.HasRoleIn(2, "admin"))
+	assert.True(t, u.HasRoleIn(3, "owner"))
+	assert.Nil(t, u.RolesIn(4))
+	noTeam := &User{UID: "bob", Roles: Names{"admin"}}
+	assert.False(t, noTeam.InTeam(0))
+	assert.Nil(t, noTeam.RolesIn(0))
+
+	token, _ := u.Encode()
+	var got User
+	assert.Nil(t, got.Decode(token))
+	assert.Equal(t, u.Teams, got.Teams)
+
+	h := RequireRoles("admin", "owner")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(ctx context.Context) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+	ctx := ContextWithUser(context.Background(), u)
+	assert.Equal(t, http.StatusOK, do(ctx))
+	assert.Equal(t, http.StatusOK, do(ContextWithTenant(ctx, 1)))
+	assert.Equal(t, http.StatusForbidden, do(ContextWithTenant(ctx, 2)))
+	assert.Equal(t, http.StatusOK, do(ContextWithTenant(ctx, 3)))
+	assert.Equal(t, Names{"viewer"}, RolesInContext(ContextWithTenant(ctx, 2), u))
+	assert.Equal(t, Names{"admin"}, RolesInContext(ctx, u))
+	assert.Equal(t, http.StatusUnauthorized, do(context.Background()))
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TOTP parameters, see RFC 6238
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret generate a random base32 secret for enrollment
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+// TOTPURI return an otpauth URI for QR code of authenticator apps
+func TOTPURI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(TOTPDigits))
+	v.Set("period", fmt.Sprint(TOTPPeriod))
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + issuer + ":" + account, RawQuery: v.Encode()}
+	return u.String()
+}
+
+// TOTPCode return the code of secret at time t
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Unix()/TOTPPeriod)), nil
+}
+
+// VerifyTOTP checks code of secret at time t, allow one period of clock skew,
+// it does not prevent replay, see ValidateTOTP
+func VerifyTOTP(secret, code string, t time.Time) bool {
+	_, ok := ValidateTOTP(secret, code, t)
+	return ok
+}
+
+// ValidateTOTP checks code of secret at time t like VerifyTOTP, return the matched time step,
+// a verifier must not accept a step again (RFC 6238 section 5.2)
+func ValidateTOTP(secret, code string, t time.Time) (uint64, bool) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+	counter := uint64(t.Unix() / TOTPPeriod)
+	for _, c := range []uint64{counter - 1, counter, counter + 1} {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, c)), []byte(code)) == 1 {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// hotp see RFC 4226
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, n%1000000)
+}
+
+// TOTPLookup find the enrolled TOTP secret of a user
+type TOTPLookup interface {
+	TOTPSecret(ctx context.Context, uid string) (string, error)
+}
+
+// TOTPCounters record the last accepted time step of users
+type TOTPCounters interface {
+	// Accept record counter of uid, return false if it is not after the last accepted one
+	Accept(ctx context.Context, uid string, counter uint64) (bool, error)
+}
+
+type memTOTPCounters struct {
+	mu    sync.Mutex
+	items map[string]uint64
+}
+
+// NewTOTPCounters return an in-memory TOTPCounters
+func NewTOTPCounters() TOTPCounters {
+	return &memTOTPCounters{items: make(map[string]uint64)}
+}
+
+func (s *memTOTPCounters) Accept(ctx context.Context, uid string, counter uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.items) > 1000 {
+		old := uint64(time.Now().Unix()/TOTPPeriod) - 2
+		for k, c := range s.items {
+			if c < old {
+				delete(s.items, k)
+			}
+		}
+	}
+	if last, ok := s.items[uid]; ok && counter <= last {
+		return false, nil
+	}
+	s.items[uid] = counter
+	return true, nil
+}
+
+type totpOption struct {
+	Limiter  Limiter
+	Counters TOTPCounters
+}
+
+// TOTPOptFunc ...
+type TOTPOptFunc func(opt *totpOption)
+
+// WithTOTPLimiter set the failed-attempt limiter keyed by uid, default: 5 failures lock 15 minutes
+func WithTOTPLimiter(l Limiter) TOTPOptFunc {
+	return func(opt *totpOption) {
+		if l != nil {
+			opt.Limiter = l
+		}
+	}
+}
+
+// WithTOTPCounters set the store of accepted time steps, default: in-memory
+func WithTOTPCounters(c TOTPCounters) TOTPOptFunc {
+	return func(opt *totpOption) {
+		if c != nil {
+			opt.Counters = c
+		}
+	}
+}
+
+// TOTPHandler handle POST form with code of current user, on success record
+// the otp method, call Signin and redirect to the stored return URL.
+// Failures are limited per user and a code is never accepted twice
+func TOTPHandler(a Authorizer, lookup TOTPLookup, redirect string, opts ...TOTPOptFunc) http.Handler {
+	if len(redirect) == 0 {
+		redirect = "/"
+	}
+	to := &totpOption{
+		Limiter:  NewLimiter(5, 15*time.Minute),
+		Counters: NewTOTPCounters(),
+	}
+	for _, fn := range opts {
+		fn(to)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		user, err := a.UserFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		key := "totp:" + user.UID
+		if to.Limiter.Locked(key) {
+			slog.Info("totp locked", "uid", user.UID)
+			http.Error(w, ErrLoginLocked.Error(), http.StatusTooManyRequests)
+			return
+		}
+		secret, err := lookup.TOTPSecret(r.Context(), user.UID)
+		counter, ok := ValidateTOTP(secret, r.PostFormValue("code"), time.Now())
+		if err == nil && ok {
+			ok, err = to.Counters.Accept(r.Context(), user.UID, counter)
+		}
+		if err != nil || !ok {
+			to.Limiter.Fail(key)
+			slog.Info("totp verify fail", "uid", user.UID, "err", err)
+			http.Error(w, "invalid code", http.StatusUnauthorized)
+			return
+		}
+		to.Limiter.Reset(key)
+		user.Refresh()
+		user.Authenticated(AmrOTP, AmrMFA)
+		if err = a.Signin(user, w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, popReturn(w, r, redirect), http.StatusSeeOther)
+	})
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockTOTP string
+
+func (m mockTOTP) TOTPSecret(ctx context.Context, uid string) (string, error) {
+	return string(m), nil
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 test vector, secret "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	code, err := TOTPCode(secret, time.Unix(59, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, "287082", code)
+	assert.True(t, VerifyTOTP(secret, "287082", time.Unix(59, 0)))
+	assert.True(t, VerifyTOTP(secret, "287082", time.Unix(80, 0)))
+	assert.False(t, VerifyTOTP(secret, "287082", time.Unix(200, 0)))
+	assert.False(t, VerifyTOTP(secret, "28708", time.Unix(59, 0)))
+
+	s, err := NewTOTPSecret()
+	assert.Nil(t, err)
+	assert.Len(t, s, 32)
+	uri := TOTPURI("Example", "alice", s)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Example:alice?"))
+	assert.Contains(t, uri, "secret="+s)
+}
+
+func TestRequireFreshAuth(t *testing.T) {
+	a := New(WithStepURI("/mfa"), WithSecret(testSecret))
+	h := a.Middleware()(RequireFreshAuth(a, 5*time.Minute, AmrOTP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	do := func(u *User) *httptest.ResponseRecorder {
+		u.Refresh()
+		token := signToken(t, a, u)
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	u := &User{UID: "alice"}
+	u.Authenticated(AmrPassword)
+	w := do(u)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/mfa", w.Header().Get("Location"))
+
+	u.Authenticated(AmrOTP)
+	assert.Equal(t, http.StatusNoContent, do(u).Code)
+
+	u.AuthTime = time.Now().Add(-10 * time.Minute).Unix()
+	assert.Equal(t, http.StatusFound, do(u).Code)
+
+	// forged claims of an unsigned token
+	u.Authenticated(AmrOTP)
+	forged, _ := u.Encode()
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+forged)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// StepURI of the primary in a chain
+	h = RequireFreshAuth(Chain(a, New()), 5*time.Minute, AmrOTP)(http.NotFoundHandler())
+	u.AuthTime = time.Now().Add(-10 * time.Minute).Unix()
+	w = do(u)
+	assert.Equal(t, "/mfa", w.Header().Get("Location"))
+
+	// without secret
+	h = RequireFreshAuth(New(), time.Minute)(http.NotFoundHandler())
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// without StepURI
+	a = New(WithSecret(testSecret))
+	h = RequireFreshAuth(a, time.Minute)(http.NotFoundHandler())
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestTOTPHandler(t *testing.T) {
+	secret, _ := NewTOTPSecret()
+	a := New(WithSecret(testSecret))
+	h := TOTPHandler(a, mockTOTP(secret), "", WithTOTPLimiter(NewLimiter(2, time.Minute)))
+
+	u := &User{UID: "alice"}
+	u.Refresh()
+	u.Authenticated(AmrPassword)
+	token := signToken(t, a, u)
+	post := func(code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mfa", strings.NewReader(url.Values{"code": {code}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(a.Cooking(token))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post("000000x").Code)
+
+	code, _ := TOTPCode(secret, time.Now())
+	w := post(code)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	got, err := a.(*option).parseToken(w.Result().Cookies()[0].Value)
+	assert.Nil(t, err)
+	assert.True(t, got.IsFreshAuth(time.Minute, AmrPassword, AmrOTP, AmrMFA))
+
+	// replay
+	assert.Equal(t, http.StatusUnauthorized, post(code).Code)
+	// locked after 2 failures
+	assert.Equal(t, http.StatusUnauthorized, post("00000x").Code)
+	assert.Equal(t, http.StatusTooManyRequests, post(code).Code)
+}
+
+func TestTOTPCounters(t *testing.T) {
+	c := NewTOTPCounters()
+	ctx := context.Background()
+	for _, tc := range []struct {
+		uid     string
+		counter uint64
+		ok      bool
+	}{
+		{"alice", 10, true},
+		{"alice", 10, false},
+		{"alice", 9, false},
+		{"alice", 11, true},
+		{"bob", 10, true},
+	} {
+		ok, err := c.Accept(ctx, tc.uid, tc.counter)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.ok, ok, tc)
+	}
+
+	now := time.Now()
+	code, _ := TOTPCode("JBSWY3DPEHPK3PXP", now)
+	n, ok := ValidateTOTP("JBSWY3DPEHPK3PXP", code, now)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(now.Unix()/TOTPPeriod), n)
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+)
+
+// Claims an application-defined user type, usually a pointer to struct,
+// *User is a Claims
+type Claims interface {
+	Encoder
+	Decode(s string) error
+	IsExpired() bool
+}
+
+// ErrTypedBinding Typed of other claims than *User can't check WithBinding and WithDPoP
+var ErrTypedBinding = errors.New("binding and DPoP need *User claims")
+
+// Refresher optional interface of Claims, used for auto refresh (WithRefresh),
+// Refresh is called on a copy of the decoded value, which is sent as the new token
+type Refresher interface {
+	NeedRefresh() bool
+	Refresh()
+}
+
+type typedKey[T Claims] struct{}
+
+// ContextWith return a context with user of type T
+func ContextWith[T Claims](ctx context.Context, user T) context.Context {
+	return context.WithValue(ctx, typedKey[T]{}, user)
+}
+
+// FromContext get user of type T from context
+func FromContext[T Claims](ctx context.Context) (user T, ok bool) {
+	if ctx == nil {
+		return
+	}
+	user, ok = ctx.Value(typedKey[T]{}).(T)
+	return
+}
+
+// Typed an authorizer for application-defined Claims, with the options of New.
+// If T is *User, it works as New with all options, the user is also in UserFromContext,
+// otherwise only
+// tokens are read (cookie, header, param) with secret and revoker, RefreshPolicy is
+// not applied and WithBinding or WithDPoP fail with ErrTypedBinding
+type Typed[T Claims] struct {
+	opt *option
+}
+
+// NewTyped build a Typed with options
+func NewTyped[T Claims](opts ...OptFunc) *Typed[T] {
+	opt := new(option)
+	opt.setDefaults()
+	opt.With(opts...)
+	return &Typed[T]{opt: opt}
+}
+
+// Middleware ...
+func (ta *Typed[T]) Middleware() func(next http.Handler) http.Handler {
+	return ta.MiddlewareWordy(false)
+}
+
+// MiddlewareWordy ...
+func (ta *Typed[T]) MiddlewareWordy(redir bool) func(next http.Handler) http.Handler {
+	opt := ta.opt
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			user, err := ta.FromRequest(req)
+			if err != nil {
+				opt.deny(rw, req, err, redir)
+				return
+			}
+			if u, ok := any(user).(*User); ok { // audit, refresh and UserFromContext as New
+				opt.serveUser(rw, req.WithContext(ContextWith(req.Context(), user)), u, next)
+				return
+			}
+			if rf, ok := any(user).(Refresher); ok && opt.needRefresh(rf) {
+				if fresh, err := refreshed(user); err == nil {
+					_, src := opt.tokenFrom(req.Header, req)
+					opt.emitRefresh(rw, fresh, src)
+				} else {
+					slog.Info("refresh fail", "err", err)
+				}
+			}
+
+			req = req.WithContext(ContextWith(req.Context(), user))
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// FromRequest decode user of type T from request
+func (ta *Typed[T]) FromRequest(r *http.Request) (user T, err error) {
+	if _, ok := any(user).(*User); ok {
+		var u *User
+		if u, err = ta.opt.UserFromRequest(r); err == nil {
+			user = any(u).(T)
+		}
+		return
+	}
+	if ta.opt.Binding != 0 || ta.opt.DPoP != nil {
+		err = ErrTypedBinding
+		return
+	}
+	var payload string
+	payload, err = ta.opt.validToken(r)
+	if err != nil {
+		return
+	}
+	user = newClaims[T]()
+	if err = user.Decode(payload); err != nil {
+		slog.Info("decode fail", "payload", payload, "err", err)
+		return
+	}
+	if user.IsExpired() {
+		slog.Info("expired", "payload", payload)
+		err = fmt.Errorf("token is expired")
+	}
+	return
+}
+
+// Signin write user encoded string into cookie
+func (ta *Typed[T]) Signin(user T, w http.ResponseWriter) error {
+	return ta.opt.Signin(user, w)
+}
+
+// Signout setcookie with empty
+func (ta *Typed[T]) Signout(w http.ResponseWriter) {
+	ta.opt.Signout(w)
+}
+
+// Cooking ...
+func (ta *Typed[T]) Cooking(value string) *http.Cookie {
+	return ta.opt.Cooking(value)
+}
+
+// refreshed return a refreshed copy of user, the user in context is never changed
+func refreshed[T Claims](user T) (T, error) {
+	s, err := user.Encode()
+	if err != nil {
+		return user, err
+	}
+	fresh := newClaims[T]()
+	if err = fresh.Decode(s); err != nil {
+		return user, err
+	}
+	any(fresh).(Refresher).Refresh()
+	return fresh, nil
+}
+
+// newClaims return a new T, allocate the struct if T is a pointer
+func newClaims[T Claims]() T {
+	var zero T
+	rt := reflect.TypeFor[T]()
+	if rt.Kind() == reflect.Pointer {
+		return reflect.New(rt.Elem()).Interface().(T)
+	}
+	return zero
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// appUser an application-defined type encoded with JSON
+type appUser struct {
+	ID      int64    `json:"id"`
+	Email   string   `json:"email"`
+	Plan    string   `json:"plan"`
+	Perms   []string `json:"perms"`
+	Updated int64    `json:"updated"`
+}
+
+func (u *appUser) Encode() (string, error) {
+	b, err := json.Marshal(u)
+	return base64.RawURLEncoding.EncodeToString(b), err
+}
+
+func (u *appUser) Decode(s string) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, u)
+}
+
+func (u *appUser) IsExpired() bool {
+	return u.Updated+3600 < time.Now().Unix()
+}
+
+func TestTyped(t *testing.T) {
+	ta := NewTyped[*appUser](WithCookie("_app"))
+
+	w := httptest.NewRecorder()
+	in := &appUser{ID: 7, Email: "a@example.net", Plan: "pro", Perms: []string{"read"}, Updated: time.Now().Unix()}
+	assert.Nil(t, ta.Signin(in, w))
+	ck := w.Result().Cookies()[0]
+	assert.Equal(t, "_app", ck.Name)
+
+	h := ta.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := FromContext[*appUser](r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, in, u)
+		_, ok = UserFromContext(r.Context())
+		assert.False(t, ok)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(ck)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	in.Updated = time.Now().Unix() - 7200
+	token, _ := in.Encode()
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(ta.Cooking(token))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	ta.Signout(w)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "Max-Age=0")
+
+	// binding can't be checked on other claims
+	ta = NewTyped[*appUser](WithCookie("_app"), WithBinding(BindUserAgent, BindReject))
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(ck)
+	_, err := ta.FromRequest(req)
+	assert.Equal(t, ErrTypedBinding, err)
+}
+
+func TestTypedUser(t *testing.T) {
+	ta := NewTyped[*User](WithRefresh())
+	user := &User{UID: "alice"}
+	user.LastHit = time.Now().Unix() - DefaultLifetime*2/3
+	token, _ := user.Encode()
+
+	h := ta.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := FromContext[*User](r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "alice", u.UID)
+		// the refreshed token is a copy
+		assert.Equal(t, user.LastHit, u.LastHit)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "_user=")
+
+	// RefreshPolicy applies to *User
+	ta = NewTyped[*User](WithRefreshPolicy(FixedWindow(time.Hour, time.Minute)))
+	h = ta.Middleware()(http.NotFoundHandler())
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+
+	// impersonated users are audited and in UserFromContext for other middlewares
+	var audited []string
+	ta = NewTyped[*User](WithSecret(testSecret), WithImpersonationAudit(func(r *http.Request, u *User) {
+		audited = append(audited, u.UID)
+	}))
+	imp := &User{UID: "bob", Actor: &Actor{UID: "alice"}}
+	imp.Refresh()
+	token, _ = ta.opt.encodeToken(imp)
+	h = ta.Middleware()(DenyImpersonation()(http.NotFoundHandler()))
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, []string{"bob"}, audited)
+
+	// all options of New apply to *User, e.g. binding
+	ta = NewTyped[*User](WithBinding(BindUserAgent, BindReject), WithSecret(testSecret))
+	token, _ = ta.opt.encodeToken(user)
+	req.Header.Set("Authorization", "Bearer "+token)
+	_, err := ta.FromRequest(req)
+	assert.Equal(t, ErrBindingMismatch, err)
+}
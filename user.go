@@ -2,6 +2,7 @@ package auth
 
 import (
 	"encoding/base64"
+	"errors"
 	"log/slog"
 	"slices"
 	"strings"
@@ -16,21 +17,63 @@ type IUser interface {
 	GetAvatar() string
 }
 
+// IRoler optional interface of IUser, see ToUser
+type IRoler interface {
+	GetRoles() []string
+}
+
+// ITeamer optional interface of IUser, see ToUser
+type ITeamer interface {
+	GetTeamID() int64
+}
+
+// IWatcher optional interface of IUser, see ToUser
+type IWatcher interface {
+	GetWatchings() []string
+}
+
+// ToUser convert an IUser, roles, team and watchings are copied if u implements
+// IRoler, ITeamer or IWatcher
 func ToUser(u IUser) User {
-	return User{
+	user := User{
 		OID:    u.GetOID(),
 		UID:    u.GetUID(),
 		Name:   u.GetName(),
 		Avatar: u.GetAvatar(),
 	}
+	if v, ok := u.(IRoler); ok {
+		user.Roles = Names(v.GetRoles())
+	}
+	if v, ok := u.(ITeamer); ok {
+		user.TeamID = v.GetTeamID()
+	}
+	if v, ok := u.(IWatcher); ok {
+		user.Watchings = Names(v.GetWatchings())
+	}
+	return user
 }
 
 //go:generate msgp -io=false
 
+// authentication methods, see RFC 8176
+const (
+	AmrPassword = "pwd"
+	AmrOTP      = "otp"
+	AmrMFA      = "mfa"
+	AmrFed      = "fed"
+	AmrLink     = "link" // one-time login link, not in RFC 8176
+	AmrMTLS     = "mtls" // TLS client certificate, not in RFC 8176
+)
+
 // vars
 var (
 	DefaultLifetime int64 = 3600
 	Guest                 = &User{}
+
+	// MaxAttrsSize max encoded size of User.Extra in bytes, checked by Encode
+	MaxAttrsSize = 1024
+
+	ErrAttrsTooLarge = errors.New("user extra attrs too large")
 )
 
 // Names ...
@@ -41,6 +84,57 @@ func (z Names) Has(name string) bool {
 	return slices.Contains(z, name)
 }
 
+// Attrs custom claims of User, values should be string, bool, number or []string
+type Attrs map[string]any
+
+// GetString ...
+func (z Attrs) GetString(k string) string {
+	s, _ := z[k].(string)
+	return s
+}
+
+// GetInt return integer value, also accept float in JSON
+func (z Attrs) GetInt(k string) int64 {
+	switch v := z[k].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// GetBool ...
+func (z Attrs) GetBool(k string) bool {
+	b, _ := z[k].(bool)
+	return b
+}
+
+// GetStrings return string slice, also accept []any after decoding
+func (z Attrs) GetStrings(k string) []string {
+	switch v := z[k].(type) {
+	case []string:
+		return v
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
 // User 在线用户
 type User struct {
 	OID       string `json:"oid,omitzero" msg:"i"` // pk id, objectID, see define in andvari
@@ -51,6 +145,56 @@ type User struct {
 	TeamID    int64  `json:"tid,omitzero" msg:"t"`
 	Roles     Names  `json:"roles,omitzero" msg:"r"`
 	Watchings Names  `json:"watching,omitzero" msg:"w"`
+	AuthMeths Names  `json:"amr,omitzero" msg:"m,omitempty"`        // authentication methods, see AmrPassword
+	AuthTime  int64  `json:"auth_time,omitzero" msg:"at,omitempty"` // time of last authentication
+	Expiry    int64  `json:"exp,omitzero" msg:"e,omitempty"`        // hard expiry of token, e.g. access token
+	Extra     Attrs  `json:"extra,omitzero" msg:"x,omitempty"`      // custom claims, see MaxAttrsSize
+	Teams     Teams  `json:"teams,omitzero" msg:"ts,omitempty"`     // memberships with per-team roles
+	Actor     *Actor `json:"act,omitzero" msg:"ac,omitempty"`       // the original user while impersonating
+	Origin    string `json:"-" msg:"og,omitempty"`                  // the encoded original user while impersonating
+	Scopes    Names  `json:"scope,omitzero" msg:"sc,omitempty"`     // granted scopes, empty for full access
+	Path      string `json:"path,omitzero" msg:"p,omitempty"`       // path prefix the token is restricted to
+	Bound     string `json:"-" msg:"b,omitempty"`                   // fingerprint of the client signed in from
+	JKT       string `json:"jkt,omitzero" msg:"jk,omitempty"`       // thumbprint of the DPoP key the token is bound to
+	Client    string `json:"client_id,omitzero" msg:"c,omitempty"`  // OAuth2 client the token is issued to, empty for first-party
+}
+
+// Actor the original user who impersonates another
+type Actor struct {
+	OID   string `json:"oid,omitzero" msg:"i"`
+	UID   string `json:"uid" msg:"u"`
+	Name  string `json:"name" msg:"n"`
+	Roles Names  `json:"roles,omitzero" msg:"r"`
+}
+
+// Membership roles of user in a team
+type Membership struct {
+	TeamID int64 `json:"tid" msg:"t"`
+	Roles  Names `json:"roles,omitzero" msg:"r"`
+}
+
+// Teams ...
+type Teams []Membership
+
+// Get return the membership of team tid
+func (z Teams) Get(tid int64) (Membership, bool) {
+	for _, m := range z {
+		if m.TeamID == tid {
+			return m, true
+		}
+	}
+	return Membership{}, false
+}
+
+var (
+	_ IUser    = User{}
+	_ IRoler   = User{}
+	_ ITeamer  = User{}
+	_ IWatcher = User{}
+)
+
+func (u User) GetOID() string {
+	return u.OID
 }
 
 func (u User) GetUID() string {
@@ -61,6 +205,75 @@ func (u User) GetName() string {
 	return u.Name
 }
 
+func (u User) GetAvatar() string {
+	return u.Avatar
+}
+
+func (u User) GetRoles() []string {
+	return u.Roles
+}
+
+func (u User) GetTeamID() int64 {
+	return u.TeamID
+}
+
+func (u User) GetWatchings() []string {
+	return u.Watchings
+}
+
+// InTeam checks if the user belongs to team tid, 0 is no team
+func (u *User) InTeam(tid int64) bool {
+	if tid <= 0 {
+		return false
+	}
+	if u.TeamID == tid {
+		return true
+	}
+	_, ok := u.Teams.Get(tid)
+	return ok
+}
+
+// RolesIn return roles of the user in team tid, the global Roles for TeamID without membership,
+// nil for 0 which is no team
+func (u *User) RolesIn(tid int64) Names {
+	if tid <= 0 {
+		return nil
+	}
+	if m, ok := u.Teams.Get(tid); ok {
+		return m.Roles
+	}
+	if u.TeamID == tid {
+		return u.Roles
+	}
+	return nil
+}
+
+// HasRoleIn checks if the user has role in team tid
+func (u *User) HasRoleIn(tid int64, role string) bool {
+	return u.RolesIn(tid).Has(role)
+}
+
+// SetTeamRoles add or replace the membership of team tid
+func (u *User) SetTeamRoles(tid int64, roles ...string) {
+	for i := range u.Teams {
+		if u.Teams[i].TeamID == tid {
+			u.Teams[i].Roles = roles
+			return
+		}
+	}
+	u.Teams = append(u.Teams, Membership{TeamID: tid, Roles: roles})
+}
+
+// HasAnyRole checks if the user has one of roles
+func (u *User) HasAnyRole(roles ...string) bool {
+	for _, r := range roles {
+		if u.Roles.Has(r) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsExpired ...
 func (u *User) IsExpired() bool {
 	return u.IsExpiredWith(DefaultLifetime)
@@ -68,12 +281,24 @@ func (u *User) IsExpired() bool {
 
 // IsExpiredWith checks if the user is expired with given lifetime in seconds.
 func (u *User) IsExpiredWith(lifetime int64) bool {
+	if u.Expiry > 0 && u.Expiry < time.Now().Unix() {
+		return true
+	}
 	if lifetime <= 0 {
 		return false
 	}
 	return u.LastHit+int64(lifetime) < time.Now().Unix()
 }
 
+// ExpiresAt return the expiry time in Unix with DefaultLifetime
+func (u *User) ExpiresAt() int64 {
+	exp := u.LastHit + DefaultLifetime
+	if u.Expiry > 0 && (DefaultLifetime <= 0 || u.Expiry < exp) {
+		exp = u.Expiry
+	}
+	return exp
+}
+
 // NeedRefresh checks if the user needs refresh.
 func (u *User) NeedRefresh() bool {
 	return u.NeedRefreshWith(DefaultLifetime)
@@ -89,6 +314,42 @@ func (u *User) NeedRefreshWith(lifetime int64) bool {
 	return gash < lifetime && gash > lifetime/2
 }
 
+// Authenticated record a successful authentication with methods at now
+func (u *User) Authenticated(methods ...string) {
+	u.AuthTime = time.Now().Unix()
+	for _, m := range methods {
+		if !u.AuthMeths.Has(m) {
+			u.AuthMeths = append(u.AuthMeths, m)
+		}
+	}
+}
+
+// IsFreshAuth checks if the last authentication is within maxAge and has all methods
+func (u *User) IsFreshAuth(maxAge time.Duration, methods ...string) bool {
+	if u.AuthTime+int64(maxAge/time.Second) < time.Now().Unix() {
+		return false
+	}
+	for _, m := range methods {
+		if !u.AuthMeths.Has(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsImpersonated checks if the user is impersonated by an Actor
+func (u *User) IsImpersonated() bool {
+	return u.Actor != nil
+}
+
+// SetAttr set a custom claim
+func (u *User) SetAttr(k string, v any) {
+	if u.Extra == nil {
+		u.Extra = make(Attrs)
+	}
+	u.Extra[k] = v
+}
+
 // Refresh lastHit to time Unix
 func (u *User) Refresh() {
 	u.LastHit = time.Now().Unix()
@@ -97,6 +358,15 @@ func (u *User) Refresh() {
 // Encode ...
 func (u User) Encode() (s string, err error) {
 	var b []byte
+	if len(u.Extra) > 0 {
+		if b, err = u.Extra.MarshalMsg(nil); err != nil {
+			return
+		}
+		if len(b) > MaxAttrsSize {
+			err = ErrAttrsTooLarge
+			return
+		}
+	}
 	b, err = u.MarshalMsg(nil)
 	if err == nil {
 		s = strings.TrimRight(base64.URLEncoding.EncodeToString(b), "=")
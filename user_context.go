@@ -9,6 +9,8 @@ type ctxKey int
 // consts
 const (
 	UserKey ctxKey = iota
+	TenantKey
+	crossKey // a super-admin crossed into the tenant
 )
 
 // ContextWithUser ...
@@ -26,3 +28,17 @@ func UserFromContext(ctx context.Context) (*User, bool) {
 	}
 	return nil, false
 }
+
+// ContextWithTenant ...
+func ContextWithTenant(ctx context.Context, tid int64) context.Context {
+	return context.WithValue(ctx, TenantKey, tid)
+}
+
+// TenantFromContext ...
+func TenantFromContext(ctx context.Context) (int64, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	tid, ok := ctx.Value(TenantKey).(int64)
+	return tid, ok
+}
@@ -6,6 +6,248 @@ import (
 	"github.com/tinylib/msgp/msgp"
 )
 
+// MarshalMsg implements msgp.Marshaler
+func (z *Actor) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 4
+	// string "i"
+	o = append(o, 0x84, 0xa1, 0x69)
+	o = msgp.AppendString(o, z.OID)
+	// string "u"
+	o = append(o, 0xa1, 0x75)
+	o = msgp.AppendString(o, z.UID)
+	// string "n"
+	o = append(o, 0xa1, 0x6e)
+	o = msgp.AppendString(o, z.Name)
+	// string "r"
+	o = append(o, 0xa1, 0x72)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.Roles)))
+	for za0001 := range z.Roles {
+		o = msgp.AppendString(o, z.Roles[za0001])
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Actor) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "i":
+			z.OID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "OID")
+				return
+			}
+		case "u":
+			z.UID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "UID")
+				return
+			}
+		case "n":
+			z.Name, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Name")
+				return
+			}
+		case "r":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Roles")
+				return
+			}
+			if cap(z.Roles) >= int(zb0002) {
+				z.Roles = (z.Roles)[:zb0002]
+			} else {
+				z.Roles = make(Names, zb0002)
+			}
+			for za0001 := range z.Roles {
+				z.Roles[za0001], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Roles", za0001)
+					return
+				}
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *Actor) Msgsize() (s int) {
+	s = 1 + 2 + msgp.StringPrefixSize + len(z.OID) + 2 + msgp.StringPrefixSize + len(z.UID) + 2 + msgp.StringPrefixSize + len(z.Name) + 2 + msgp.ArrayHeaderSize
+	for za0001 := range z.Roles {
+		s += msgp.StringPrefixSize + len(z.Roles[za0001])
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z Attrs) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	o = msgp.AppendMapHeader(o, uint32(len(z)))
+	for za0001, za0002 := range z {
+		o = msgp.AppendString(o, za0001)
+		o, err = msgp.AppendIntf(o, za0002)
+		if err != nil {
+			err = msgp.WrapError(err, za0001)
+			return
+		}
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Attrs) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var zb0003 uint32
+	zb0003, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if (*z) == nil {
+		(*z) = make(Attrs, zb0003)
+	} else if len((*z)) > 0 {
+		for key := range *z {
+			delete((*z), key)
+		}
+	}
+	var field []byte
+	_ = field
+	for zb0003 > 0 {
+		var zb0001 string
+		var zb0002 interface{}
+		zb0003--
+		zb0001, bts, err = msgp.ReadStringBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		zb0002, bts, err = msgp.ReadIntfBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err, zb0001)
+			return
+		}
+		(*z)[zb0001] = zb0002
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z Attrs) Msgsize() (s int) {
+	s = msgp.MapHeaderSize
+	if z != nil {
+		for zb0004, zb0005 := range z {
+			_ = zb0005
+			s += msgp.StringPrefixSize + len(zb0004) + msgp.GuessSize(zb0005)
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *Membership) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 2
+	// string "t"
+	o = append(o, 0x82, 0xa1, 0x74)
+	o = msgp.AppendInt64(o, z.TeamID)
+	// string "r"
+	o = append(o, 0xa1, 0x72)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.Roles)))
+	for za0001 := range z.Roles {
+		o = msgp.AppendString(o, z.Roles[za0001])
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Membership) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "t":
+			z.TeamID, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "TeamID")
+				return
+			}
+		case "r":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Roles")
+				return
+			}
+			if cap(z.Roles) >= int(zb0002) {
+				z.Roles = (z.Roles)[:zb0002]
+			} else {
+				z.Roles = make(Names, zb0002)
+			}
+			for za0001 := range z.Roles {
+				z.Roles[za0001], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Roles", za0001)
+					return
+				}
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *Membership) Msgsize() (s int) {
+	s = 1 + 2 + msgp.Int64Size + 2 + msgp.ArrayHeaderSize
+	for za0001 := range z.Roles {
+		s += msgp.StringPrefixSize + len(z.Roles[za0001])
+	}
+	return
+}
+
 // MarshalMsg implements msgp.Marshaler
 func (z Names) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
@@ -49,39 +291,289 @@ func (z Names) Msgsize() (s int) {
 	return
 }
 
+// MarshalMsg implements msgp.Marshaler
+func (z Teams) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	o = msgp.AppendArrayHeader(o, uint32(len(z)))
+	for za0001 := range z {
+		// map header, size 2
+		// string "t"
+		o = append(o, 0x82, 0xa1, 0x74)
+		o = msgp.AppendInt64(o, z[za0001].TeamID)
+		// string "r"
+		o = append(o, 0xa1, 0x72)
+		o = msgp.AppendArrayHeader(o, uint32(len(z[za0001].Roles)))
+		for za0002 := range z[za0001].Roles {
+			o = msgp.AppendString(o, z[za0001].Roles[za0002])
+		}
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Teams) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var zb0003 uint32
+	zb0003, bts, err = msgp.ReadArrayHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if cap((*z)) >= int(zb0003) {
+		(*z) = (*z)[:zb0003]
+	} else {
+		(*z) = make(Teams, zb0003)
+	}
+	for zb0001 := range *z {
+		var field []byte
+		_ = field
+		var zb0004 uint32
+		zb0004, bts, err = msgp.ReadMapHeaderBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err, zb0001)
+			return
+		}
+		for zb0004 > 0 {
+			zb0004--
+			field, bts, err = msgp.ReadMapKeyZC(bts)
+			if err != nil {
+				err = msgp.WrapError(err, zb0001)
+				return
+			}
+			switch msgp.UnsafeString(field) {
+			case "t":
+				(*z)[zb0001].TeamID, bts, err = msgp.ReadInt64Bytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, zb0001, "TeamID")
+					return
+				}
+			case "r":
+				var zb0005 uint32
+				zb0005, bts, err = msgp.ReadArrayHeaderBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, zb0001, "Roles")
+					return
+				}
+				if cap((*z)[zb0001].Roles) >= int(zb0005) {
+					(*z)[zb0001].Roles = ((*z)[zb0001].Roles)[:zb0005]
+				} else {
+					(*z)[zb0001].Roles = make(Names, zb0005)
+				}
+				for zb0002 := range (*z)[zb0001].Roles {
+					(*z)[zb0001].Roles[zb0002], bts, err = msgp.ReadStringBytes(bts)
+					if err != nil {
+						err = msgp.WrapError(err, zb0001, "Roles", zb0002)
+						return
+					}
+				}
+			default:
+				bts, err = msgp.Skip(bts)
+				if err != nil {
+					err = msgp.WrapError(err, zb0001)
+					return
+				}
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z Teams) Msgsize() (s int) {
+	s = msgp.ArrayHeaderSize
+	for zb0006 := range z {
+		s += 1 + 2 + msgp.Int64Size + 2 + msgp.ArrayHeaderSize
+		for zb0007 := range z[zb0006].Roles {
+			s += msgp.StringPrefixSize + len(z[zb0006].Roles[zb0007])
+		}
+	}
+	return
+}
+
 // MarshalMsg implements msgp.Marshaler
 func (z *User) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 8
-	// string "i"
-	o = append(o, 0x88, 0xa1, 0x69)
-	o = msgp.AppendString(o, z.OID)
-	// string "u"
-	o = append(o, 0xa1, 0x75)
-	o = msgp.AppendString(o, z.UID)
-	// string "n"
-	o = append(o, 0xa1, 0x6e)
-	o = msgp.AppendString(o, z.Name)
-	// string "a"
-	o = append(o, 0xa1, 0x61)
-	o = msgp.AppendString(o, z.Avatar)
-	// string "h"
-	o = append(o, 0xa1, 0x68)
-	o = msgp.AppendInt64(o, z.LastHit)
-	// string "t"
-	o = append(o, 0xa1, 0x74)
-	o = msgp.AppendInt64(o, z.TeamID)
-	// string "r"
-	o = append(o, 0xa1, 0x72)
-	o = msgp.AppendArrayHeader(o, uint32(len(z.Roles)))
-	for za0001 := range z.Roles {
-		o = msgp.AppendString(o, z.Roles[za0001])
+	// check for omitted fields
+	zb0001Len := uint32(20)
+	var zb0001Mask uint32 /* 20 bits */
+	_ = zb0001Mask
+	if z.AuthMeths == nil {
+		zb0001Len--
+		zb0001Mask |= 0x100
 	}
-	// string "w"
-	o = append(o, 0xa1, 0x77)
-	o = msgp.AppendArrayHeader(o, uint32(len(z.Watchings)))
-	for za0002 := range z.Watchings {
-		o = msgp.AppendString(o, z.Watchings[za0002])
+	if z.AuthTime == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.Expiry == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
+	if z.Extra == nil {
+		zb0001Len--
+		zb0001Mask |= 0x800
+	}
+	if z.Teams == nil {
+		zb0001Len--
+		zb0001Mask |= 0x1000
+	}
+	if z.Actor == nil {
+		zb0001Len--
+		zb0001Mask |= 0x2000
+	}
+	if z.Origin == "" {
+		zb0001Len--
+		zb0001Mask |= 0x4000
+	}
+	if z.Scopes == nil {
+		zb0001Len--
+		zb0001Mask |= 0x8000
+	}
+	if z.Path == "" {
+		zb0001Len--
+		zb0001Mask |= 0x10000
+	}
+	if z.Bound == "" {
+		zb0001Len--
+		zb0001Mask |= 0x20000
+	}
+	if z.JKT == "" {
+		zb0001Len--
+		zb0001Mask |= 0x40000
+	}
+	if z.Client == "" {
+		zb0001Len--
+		zb0001Mask |= 0x80000
+	}
+	// variable map header, size zb0001Len
+	o = msgp.AppendMapHeader(o, zb0001Len)
+
+	// skip if no fields are to be emitted
+	if zb0001Len != 0 {
+		// string "i"
+		o = append(o, 0xa1, 0x69)
+		o = msgp.AppendString(o, z.OID)
+		// string "u"
+		o = append(o, 0xa1, 0x75)
+		o = msgp.AppendString(o, z.UID)
+		// string "n"
+		o = append(o, 0xa1, 0x6e)
+		o = msgp.AppendString(o, z.Name)
+		// string "a"
+		o = append(o, 0xa1, 0x61)
+		o = msgp.AppendString(o, z.Avatar)
+		// string "h"
+		o = append(o, 0xa1, 0x68)
+		o = msgp.AppendInt64(o, z.LastHit)
+		// string "t"
+		o = append(o, 0xa1, 0x74)
+		o = msgp.AppendInt64(o, z.TeamID)
+		// string "r"
+		o = append(o, 0xa1, 0x72)
+		o = msgp.AppendArrayHeader(o, uint32(len(z.Roles)))
+		for za0001 := range z.Roles {
+			o = msgp.AppendString(o, z.Roles[za0001])
+		}
+		// string "w"
+		o = append(o, 0xa1, 0x77)
+		o = msgp.AppendArrayHeader(o, uint32(len(z.Watchings)))
+		for za0002 := range z.Watchings {
+			o = msgp.AppendString(o, z.Watchings[za0002])
+		}
+		if (zb0001Mask & 0x100) == 0 { // if not omitted
+			// string "m"
+			o = append(o, 0xa1, 0x6d)
+			o = msgp.AppendArrayHeader(o, uint32(len(z.AuthMeths)))
+			for za0003 := range z.AuthMeths {
+				o = msgp.AppendString(o, z.AuthMeths[za0003])
+			}
+		}
+		if (zb0001Mask & 0x200) == 0 { // if not omitted
+			// string "at"
+			o = append(o, 0xa2, 0x61, 0x74)
+			o = msgp.AppendInt64(o, z.AuthTime)
+		}
+		if (zb0001Mask & 0x400) == 0 { // if not omitted
+			// string "e"
+			o = append(o, 0xa1, 0x65)
+			o = msgp.AppendInt64(o, z.Expiry)
+		}
+		if (zb0001Mask & 0x800) == 0 { // if not omitted
+			// string "x"
+			o = append(o, 0xa1, 0x78)
+			o = msgp.AppendMapHeader(o, uint32(len(z.Extra)))
+			for za0004, za0005 := range z.Extra {
+				o = msgp.AppendString(o, za0004)
+				o, err = msgp.AppendIntf(o, za0005)
+				if err != nil {
+					err = msgp.WrapError(err, "Extra", za0004)
+					return
+				}
+			}
+		}
+		if (zb0001Mask & 0x1000) == 0 { // if not omitted
+			// string "ts"
+			o = append(o, 0xa2, 0x74, 0x73)
+			o = msgp.AppendArrayHeader(o, uint32(len(z.Teams)))
+			for za0006 := range z.Teams {
+				// map header, size 2
+				// string "t"
+				o = append(o, 0x82, 0xa1, 0x74)
+				o = msgp.AppendInt64(o, z.Teams[za0006].TeamID)
+				// string "r"
+				o = append(o, 0xa1, 0x72)
+				o = msgp.AppendArrayHeader(o, uint32(len(z.Teams[za0006].Roles)))
+				for za0007 := range z.Teams[za0006].Roles {
+					o = msgp.AppendString(o, z.Teams[za0006].Roles[za0007])
+				}
+			}
+		}
+		if (zb0001Mask & 0x2000) == 0 { // if not omitted
+			// string "ac"
+			o = append(o, 0xa2, 0x61, 0x63)
+			if z.Actor == nil {
+				o = msgp.AppendNil(o)
+			} else {
+				o, err = z.Actor.MarshalMsg(o)
+				if err != nil {
+					err = msgp.WrapError(err, "Actor")
+					return
+				}
+			}
+		}
+		if (zb0001Mask & 0x4000) == 0 { // if not omitted
+			// string "og"
+			o = append(o, 0xa2, 0x6f, 0x67)
+			o = msgp.AppendString(o, z.Origin)
+		}
+		if (zb0001Mask & 0x8000) == 0 { // if not omitted
+			// string "sc"
+			o = append(o, 0xa2, 0x73, 0x63)
+			o = msgp.AppendArrayHeader(o, uint32(len(z.Scopes)))
+			for za0008 := range z.Scopes {
+				o = msgp.AppendString(o, z.Scopes[za0008])
+			}
+		}
+		if (zb0001Mask & 0x10000) == 0 { // if not omitted
+			// string "p"
+			o = append(o, 0xa1, 0x70)
+			o = msgp.AppendString(o, z.Path)
+		}
+		if (zb0001Mask & 0x20000) == 0 { // if not omitted
+			// string "b"
+			o = append(o, 0xa1, 0x62)
+			o = msgp.AppendString(o, z.Bound)
+		}
+		if (zb0001Mask & 0x40000) == 0 { // if not omitted
+			// string "jk"
+			o = append(o, 0xa2, 0x6a, 0x6b)
+			o = msgp.AppendString(o, z.JKT)
+		}
+		if (zb0001Mask & 0x80000) == 0 { // if not omitted
+			// string "c"
+			o = append(o, 0xa1, 0x63)
+			o = msgp.AppendString(o, z.Client)
+		}
 	}
 	return
 }
@@ -178,6 +670,194 @@ func (z *User) UnmarshalMsg(bts []byte) (o []byte, err error) {
 					return
 				}
 			}
+		case "m":
+			var zb0004 uint32
+			zb0004, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "AuthMeths")
+				return
+			}
+			if cap(z.AuthMeths) >= int(zb0004) {
+				z.AuthMeths = (z.AuthMeths)[:zb0004]
+			} else {
+				z.AuthMeths = make(Names, zb0004)
+			}
+			for za0003 := range z.AuthMeths {
+				z.AuthMeths[za0003], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "AuthMeths", za0003)
+					return
+				}
+			}
+		case "at":
+			z.AuthTime, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "AuthTime")
+				return
+			}
+		case "e":
+			z.Expiry, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Expiry")
+				return
+			}
+		case "x":
+			var zb0005 uint32
+			zb0005, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Extra")
+				return
+			}
+			if z.Extra == nil {
+				z.Extra = make(Attrs, zb0005)
+			} else if len(z.Extra) > 0 {
+				for key := range z.Extra {
+					delete(z.Extra, key)
+				}
+			}
+			for zb0005 > 0 {
+				var za0004 string
+				var za0005 interface{}
+				zb0005--
+				za0004, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Extra")
+					return
+				}
+				za0005, bts, err = msgp.ReadIntfBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Extra", za0004)
+					return
+				}
+				z.Extra[za0004] = za0005
+			}
+		case "ts":
+			var zb0006 uint32
+			zb0006, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Teams")
+				return
+			}
+			if cap(z.Teams) >= int(zb0006) {
+				z.Teams = (z.Teams)[:zb0006]
+			} else {
+				z.Teams = make(Teams, zb0006)
+			}
+			for za0006 := range z.Teams {
+				var zb0007 uint32
+				zb0007, bts, err = msgp.ReadMapHeaderBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Teams", za0006)
+					return
+				}
+				for zb0007 > 0 {
+					zb0007--
+					field, bts, err = msgp.ReadMapKeyZC(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "Teams", za0006)
+						return
+					}
+					switch msgp.UnsafeString(field) {
+					case "t":
+						z.Teams[za0006].TeamID, bts, err = msgp.ReadInt64Bytes(bts)
+						if err != nil {
+							err = msgp.WrapError(err, "Teams", za0006, "TeamID")
+							return
+						}
+					case "r":
+						var zb0008 uint32
+						zb0008, bts, err = msgp.ReadArrayHeaderBytes(bts)
+						if err != nil {
+							err = msgp.WrapError(err, "Teams", za0006, "Roles")
+							return
+						}
+						if cap(z.Teams[za0006].Roles) >= int(zb0008) {
+							z.Teams[za0006].Roles = (z.Teams[za0006].Roles)[:zb0008]
+						} else {
+							z.Teams[za0006].Roles = make(Names, zb0008)
+						}
+						for za0007 := range z.Teams[za0006].Roles {
+							z.Teams[za0006].Roles[za0007], bts, err = msgp.ReadStringBytes(bts)
+							if err != nil {
+								err = msgp.WrapError(err, "Teams", za0006, "Roles", za0007)
+								return
+							}
+						}
+					default:
+						bts, err = msgp.Skip(bts)
+						if err != nil {
+							err = msgp.WrapError(err, "Teams", za0006)
+							return
+						}
+					}
+				}
+			}
+		case "ac":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.Actor = nil
+			} else {
+				if z.Actor == nil {
+					z.Actor = new(Actor)
+				}
+				bts, err = z.Actor.UnmarshalMsg(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Actor")
+					return
+				}
+			}
+		case "og":
+			z.Origin, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Origin")
+				return
+			}
+		case "sc":
+			var zb0009 uint32
+			zb0009, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Scopes")
+				return
+			}
+			if cap(z.Scopes) >= int(zb0009) {
+				z.Scopes = (z.Scopes)[:zb0009]
+			} else {
+				z.Scopes = make(Names, zb0009)
+			}
+			for za0008 := range z.Scopes {
+				z.Scopes[za0008], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Scopes", za0008)
+					return
+				}
+			}
+		case "p":
+			z.Path, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Path")
+				return
+			}
+		case "b":
+			z.Bound, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Bound")
+				return
+			}
+		case "jk":
+			z.JKT, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "JKT")
+				return
+			}
+		case "c":
+			z.Client, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Client")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -192,7 +872,7 @@ func (z *User) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *User) Msgsize() (s int) {
-	s = 1 + 2 + msgp.StringPrefixSize + len(z.OID) + 2 + msgp.StringPrefixSize + len(z.UID) + 2 + msgp.StringPrefixSize + len(z.Name) + 2 + msgp.StringPrefixSize + len(z.Avatar) + 2 + msgp.Int64Size + 2 + msgp.Int64Size + 2 + msgp.ArrayHeaderSize
+	s = 3 + 2 + msgp.StringPrefixSize + len(z.OID) + 2 + msgp.StringPrefixSize + len(z.UID) + 2 + msgp.StringPrefixSize + len(z.Name) + 2 + msgp.StringPrefixSize + len(z.Avatar) + 2 + msgp.Int64Size + 2 + msgp.Int64Size + 2 + msgp.ArrayHeaderSize
 	for za0001 := range z.Roles {
 		s += msgp.StringPrefixSize + len(z.Roles[za0001])
 	}
@@ -200,5 +880,34 @@ func (z *User) Msgsize() (s int) {
 	for za0002 := range z.Watchings {
 		s += msgp.StringPrefixSize + len(z.Watchings[za0002])
 	}
+	s += 2 + msgp.ArrayHeaderSize
+	for za0003 := range z.AuthMeths {
+		s += msgp.StringPrefixSize + len(z.AuthMeths[za0003])
+	}
+	s += 3 + msgp.Int64Size + 2 + msgp.Int64Size + 2 + msgp.MapHeaderSize
+	if z.Extra != nil {
+		for za0004, za0005 := range z.Extra {
+			_ = za0005
+			s += msgp.StringPrefixSize + len(za0004) + msgp.GuessSize(za0005)
+		}
+	}
+	s += 3 + msgp.ArrayHeaderSize
+	for za0006 := range z.Teams {
+		s += 1 + 2 + msgp.Int64Size + 2 + msgp.ArrayHeaderSize
+		for za0007 := range z.Teams[za0006].Roles {
+			s += msgp.StringPrefixSize + len(z.Teams[za0006].Roles[za0007])
+		}
+	}
+	s += 3
+	if z.Actor == nil {
+		s += msgp.NilSize
+	} else {
+		s += z.Actor.Msgsize()
+	}
+	s += 3 + msgp.StringPrefixSize + len(z.Origin) + 3 + msgp.ArrayHeaderSize
+	for za0008 := range z.Scopes {
+		s += msgp.StringPrefixSize + len(z.Scopes[za0008])
+	}
+	s += 2 + msgp.StringPrefixSize + len(z.Path) + 2 + msgp.StringPrefixSize + len(z.Bound) + 3 + msgp.StringPrefixSize + len(z.JKT) + 2 + msgp.StringPrefixSize + len(z.Client)
 	return
 }
@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -135,6 +137,19 @@ func TestSignout(t *testing.T) {
 	w := httptest.NewRecorder()
 	Signout(w)
 	assert.Contains(t, w.Header().Get("Set-Cookie"), "Max-Age=0")
+	assert.Len(t, w.Result().Cookies(), 1)
+
+	// both the domain and the host-only cookie are expired
+	w = httptest.NewRecorder()
+	New(WithCookie("_sess", "/", "example.net")).Signout(w)
+	cks := w.Result().Cookies()
+	assert.Len(t, cks, 2)
+	assert.Equal(t, "example.net", cks[0].Domain)
+	assert.Empty(t, cks[1].Domain)
+	for _, ck := range cks {
+		assert.Equal(t, "_sess", ck.Name)
+		assert.Equal(t, -1, ck.MaxAge)
+	}
 }
 
 func TestUserFromRequest(t *testing.T) {
@@ -235,3 +250,72 @@ func TestNamesHasEmpty(t *testing.T) {
 	var names Names
 	assert.False(t, names.Has("admin"))
 }
+
+func TestUserAttrs(t *testing.T) {
+	u := &User{UID: "test"}
+	u.SetAttr("locale", "zh-CN")
+	u.SetAttr("beta", true)
+	u.SetAttr("quota", 42)
+	u.SetAttr("flags", []string{"a", "b"})
+
+	token, err := u.Encode()
+	assert.Nil(t, err)
+	var got User
+	assert.Nil(t, got.Decode(token))
+	assert.Equal(t, "zh-CN", got.Extra.GetString("locale"))
+	assert.True(t, got.Extra.GetBool("beta"))
+	assert.Equal(t, int64(42), got.Extra.GetInt("quota"))
+	assert.Equal(t, []string{"a", "b"}, got.Extra.GetStrings("flags"))
+	assert.Empty(t, got.Extra.GetString("missing"))
+
+	b, err := json.Marshal(got)
+	assert.Nil(t, err)
+	var fromJSON User
+	assert.Nil(t, json.Unmarshal(b, &fromJSON))
+	assert.Equal(t, int64(42), fromJSON.Extra.GetInt("quota"))
+	assert.Equal(t, []string{"a", "b"}, fromJSON.Extra.GetStrings("flags"))
+
+	u.SetAttr("big", strings.Repeat("x", MaxAttrsSize))
+	_, err = u.Encode()
+	assert.Equal(t, ErrAttrsTooLarge, err)
+}
+
+func TestUserDecodeOldToken(t *testing.T) {
+	// encoded before Extra and other optional fields were added
+	var u User
+	assert.Nil(t, u.Decode("iKFpoKF1o29sZKFuo09sZKFhoKFo0mVT8QChdAOhcpGlYWRtaW6hd5A"))
+	assert.Equal(t, "old", u.UID)
+	assert.Equal(t, int64(3), u.TeamID)
+	assert.Equal(t, Names{"admin"}, u.Roles)
+	assert.Nil(t, u.Extra)
+	assert.True(t, u.HasRoleIn(3, "admin"))
+}
+
+// mockMember implements IUser with roles and team
+type mockMember struct {
+	mockUser
+	roles []string
+	team  int64
+}
+
+func (m mockMember) GetRoles() []string { return m.roles }
+func (m mockMember) GetTeamID() int64   { return m.team }
+
+func TestToUserExtended(t *testing.T) {
+	m := mockMember{mockUser: mockUser{uid: "user001"}, roles: []string{"admin"}, team: 9}
+	u := ToUser(m)
+	assert.Equal(t, "user001", u.UID)
+	assert.Equal(t, Names{"admin"}, u.Roles)
+	assert.Equal(t, int64(9), u.TeamID)
+	assert.Nil(t, u.Watchings)
+}
+
+func TestUserRoundTrip(t *testing.T) {
+	u := User{OID: "oid", UID: "uid", Name: "name", Avatar: "a.png", TeamID: 3,
+		Roles: Names{"admin"}, Watchings: Names{"w1"}}
+	var iu IUser = u
+	got := ToUser(iu)
+	assert.Equal(t, u, got)
+	assert.Equal(t, "oid", u.GetOID())
+	assert.Equal(t, "a.png", u.GetAvatar())
+}